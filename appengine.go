@@ -12,14 +12,12 @@ package mysql
 
 import (
 	"appengine/cloudsql"
+	"context"
 	"net"
 )
 
 func init() {
-	if dials == nil {
-		dials = make(map[string]dialFunc)
-	}
-	dials["cloudsql"] = func(cfg *config) (net.Conn, error) {
-		return cloudsql.Dial(cfg.addr)
-	}
+	RegisterDialContext("cloudsql", func(ctx context.Context, addr string) (net.Conn, error) {
+		return cloudsql.Dial(addr)
+	})
 }
@@ -0,0 +1,205 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type queryAttributesKey struct{}
+
+type queryAttribute struct {
+	name  string
+	value driver.Value
+}
+
+// WithQueryAttributes returns a context which attaches the given query
+// attributes to the statements executed with it. The attributes can be read
+// on the server with mysql_query_attribute_string(), e.g. by audit log
+// components. Values are converted like query parameters.
+// Query attributes require MySQL 8.0.23+ and are ignored by older servers.
+//
+//  ctx = mysql.WithQueryAttributes(ctx, map[string]interface{}{
+//  	"trace_id": traceID,
+//  	"tenant":   tenantID,
+//  })
+//  rows, err := db.QueryContext(ctx, "SELECT ...")
+func WithQueryAttributes(ctx context.Context, attrs map[string]interface{}) context.Context {
+	return context.WithValue(ctx, queryAttributesKey{}, attrs)
+}
+
+// Returns the query attributes attached to ctx, sorted by name
+func queryAttributesFromContext(ctx context.Context) ([]queryAttribute, error) {
+	values, _ := ctx.Value(queryAttributesKey{}).(map[string]interface{})
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	attrs := make([]queryAttribute, 0, len(values))
+	for name, value := range values {
+		v, err := converter{}.ConvertValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid query attribute %s: %s", name, err.Error())
+		}
+		attrs = append(attrs, queryAttribute{name: name, value: v})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].name < attrs[j].name
+	})
+	return attrs, nil
+}
+
+// Sets the query attributes for the next command sent
+func (mc *mysqlConn) setQueryAttributes(ctx context.Context) error {
+	attrs, err := queryAttributesFromContext(ctx)
+	mc.queryAttributes = attrs
+	return err
+}
+
+// Returns the query attributes for the command sent now. They are only sent
+// with the first command, not with e.g. SHOW WARNINGS following it.
+func (mc *mysqlConn) takeQueryAttributes() []queryAttribute {
+	attrs := mc.queryAttributes
+	mc.queryAttributes = nil
+	if mc.flags&clientQueryAttributes == 0 {
+		return nil
+	}
+	return attrs
+}
+
+// Returns the length of the types and names of the attributes
+func queryAttributesTypesLen(attrs []queryAttribute) int {
+	n := 0
+	for _, attr := range attrs {
+		n += 2 + len(appendLengthEncodedInteger(nil, uint64(len(attr.name)))) + len(attr.name)
+	}
+	return n
+}
+
+// Writes the attributes as the parameters following the first offset
+// parameters: sets their bits in the NULL-bitmap, writes their types and
+// names to types and appends their values to values
+func (mc *mysqlConn) writeQueryAttributes(attrs []queryAttribute, offset int, nullMask, types, values []byte) ([]byte, error) {
+	pos := 0
+	for i, attr := range attrs {
+		var unsigned byte
+		var typ byte
+
+		switch v := attr.value.(type) {
+		case nil:
+			nullMask[(offset+i)/8] |= 1 << (uint(offset+i) & 7)
+			typ = fieldTypeNULL
+
+		case int64:
+			typ = fieldTypeLongLong
+			values = append(values, uint64ToBytes(uint64(v))...)
+
+		case uint64:
+			typ = fieldTypeLongLong
+			unsigned = 0x80
+			values = append(values, uint64ToBytes(v)...)
+
+		case float64:
+			typ = fieldTypeDouble
+			values = append(values, uint64ToBytes(math.Float64bits(v))...)
+
+		case bool:
+			typ = fieldTypeTiny
+			if v {
+				values = append(values, 0x01)
+			} else {
+				values = append(values, 0x00)
+			}
+
+		case []byte:
+			if v == nil {
+				nullMask[(offset+i)/8] |= 1 << (uint(offset+i) & 7)
+				typ = fieldTypeNULL
+				break
+			}
+			typ = fieldTypeString
+			values = appendLengthEncodedInteger(values, uint64(len(v)))
+			values = append(values, v...)
+
+		case string:
+			typ = fieldTypeString
+			values = appendLengthEncodedInteger(values, uint64(len(v)))
+			values = append(values, v...)
+
+		case Decimal:
+			typ = fieldTypeNewDecimal
+			val := v.String()
+			values = appendLengthEncodedInteger(values, uint64(len(val)))
+			values = append(values, val...)
+
+		case time.Time:
+			typ = fieldTypeString
+			val := "0000-00-00"
+			if !v.IsZero() {
+				val = v.In(mc.cfg.loc).Format(timeFormat)
+			}
+			values = appendLengthEncodedInteger(values, uint64(len(val)))
+			values = append(values, val...)
+
+		default:
+			return nil, fmt.Errorf("Can't convert type: %T", attr.value)
+		}
+
+		// type [2 bytes]
+		types[pos] = typ
+		types[pos+1] = unsigned
+		pos += 2
+
+		// name [length encoded string]
+		pos += copy(types[pos:], appendLengthEncodedInteger(nil, uint64(len(attr.name))))
+		pos += copy(types[pos:], attr.name)
+	}
+	return values, nil
+}
+
+// Appends the query attributes of a COM_QUERY packet
+// http://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query.html
+func (mc *mysqlConn) appendQueryAttributes(b []byte) ([]byte, error) {
+	attrs := mc.takeQueryAttributes()
+
+	// parameter_count [length encoded integer]
+	b = appendLengthEncodedInteger(b, uint64(len(attrs)))
+
+	// parameter_set_count [length encoded integer], always 1
+	b = append(b, 0x01)
+
+	if len(attrs) == 0 {
+		return b, nil
+	}
+
+	// NULL-bitmap [(parameter_count+7)/8 bytes]
+	nullMask := make([]byte, (len(attrs)+7)/8)
+
+	// type and name of each parameter
+	types := make([]byte, queryAttributesTypesLen(attrs))
+
+	// value of each parameter
+	values, err := mc.writeQueryAttributes(attrs, 0, nullMask, types, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, nullMask...)
+
+	// new_params_bind_flag, always 1 [1 byte]
+	b = append(b, 0x01)
+
+	b = append(b, types...)
+	return append(b, values...), nil
+}
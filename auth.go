@@ -0,0 +1,225 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/pem"
+	"fmt"
+)
+
+// Authentication plugins
+const (
+	authNativePassword      = "mysql_native_password"
+	authOldPassword         = "mysql_old_password"
+	authCachingSHA2Password = "caching_sha2_password"
+)
+
+// Auth More Data packets of caching_sha2_password
+const (
+	iAuthMoreData               byte = 0x01
+	cachingSHA2RequestPublicKey byte = 0x02
+	cachingSHA2FastAuthSuccess  byte = 0x03
+	cachingSHA2PerformFullAuth  byte = 0x04
+)
+
+// Hash password using MySQL 8+ method (SHA256)
+// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), scramble))
+func scrambleSHA256Password(scramble, password []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+
+	crypt := sha256.New()
+	crypt.Write(password)
+	message1 := crypt.Sum(nil)
+
+	crypt.Reset()
+	crypt.Write(message1)
+	message1Hash := crypt.Sum(nil)
+
+	crypt.Reset()
+	crypt.Write(message1Hash)
+	crypt.Write(scramble)
+	message2 := crypt.Sum(nil)
+
+	for i := range message1 {
+		message1[i] ^= message2[i]
+	}
+	return message1
+}
+
+// Encrypt the password with the public key of the server for the full
+// authentication of caching_sha2_password on insecure connections
+func encryptPassword(password string, seed []byte, pub *rsa.PublicKey) ([]byte, error) {
+	plain := make([]byte, len(password)+1)
+	copy(plain, password)
+	for i := range plain {
+		plain[i] ^= seed[i%len(seed)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+}
+
+// Returns the response to the auth data of the given plugin
+func (mc *mysqlConn) authResponse(plugin string, authData []byte) ([]byte, error) {
+	switch plugin {
+	case authNativePassword:
+		if len(authData) < 20 {
+			return nil, mc.malformedPacket()
+		}
+		return scramblePassword(authData[:20], []byte(mc.cfg.passwd)), nil
+
+	case authOldPassword:
+		if !mc.cfg.allowOldPasswords {
+			return nil, errOldPassword
+		}
+		if len(authData) < 8 {
+			return nil, mc.malformedPacket()
+		}
+		// the response is a null terminated string
+		return append(scrambleOldPassword(authData[:8], []byte(mc.cfg.passwd)), 0x00), nil
+
+	case authCachingSHA2Password:
+		if len(authData) < 20 {
+			return nil, mc.malformedPacket()
+		}
+		return scrambleSHA256Password(authData[:20], []byte(mc.cfg.passwd)), nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported auth plugin '%s'", plugin)
+	}
+}
+
+// Auth Switch Response Packet
+// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::AuthSwitchResponse
+func (mc *mysqlConn) writeAuthSwitchPacket(authResp []byte) error {
+	data := mc.buf.takeSmallBuffer(4 + len(authResp))
+	if data == nil {
+		// can not take the buffer. Something must be wrong with the connection
+		errLog.Print(errBusyBuffer)
+		return driver.ErrBadConn
+	}
+
+	copy(data[4:], authResp)
+	return mc.writePacket(data)
+}
+
+// Reads the result of an authentication, which was started with the given
+// plugin and auth data. Auth switch requests of the server are answered until
+// the server accepts or rejects the credentials.
+// http://dev.mysql.com/doc/internals/en/authentication-method-mismatch.html
+func (mc *mysqlConn) handleAuthResult(plugin string, authData []byte) error {
+	for {
+		data, err := mc.readPacket()
+		if err != nil {
+			return err
+		}
+
+		switch data[0] {
+		case iOK:
+			return mc.handleOkPacket(data)
+
+		case iEOF:
+			if len(data) == 1 {
+				// Old Authentication Method Switch Request Packet
+				plugin = authOldPassword
+			} else {
+				// Authentication Method Switch Request Packet
+				// plugin name [null terminated string], auth data [EOF]
+				end := bytes.IndexByte(data[1:], 0x00)
+				if end < 0 {
+					return mc.malformedPacket()
+				}
+				plugin = string(data[1 : 1+end])
+
+				// the server generated a new scramble, which is also
+				// used for the next COM_CHANGE_USER
+				authData = bytes.TrimSuffix(data[2+end:], []byte{0x00})
+				authData = append([]byte(nil), authData...)
+				if len(authData) >= 20 {
+					mc.cipher = authData
+				}
+			}
+
+			authResp, err := mc.authResponse(plugin, authData)
+			if err != nil {
+				return err
+			}
+			if err = mc.writeAuthSwitchPacket(authResp); err != nil {
+				return err
+			}
+
+		case iAuthMoreData:
+			if plugin != authCachingSHA2Password || len(data) < 2 {
+				return mc.malformedPacket()
+			}
+			if err = mc.handleCachingSHA2MoreData(data[1], authData); err != nil {
+				return err
+			}
+
+		default: // Error otherwise
+			return mc.handleErrorPacket(data)
+		}
+	}
+}
+
+// Continues the authentication with caching_sha2_password. If the server has
+// no cached credentials, the password is sent in plain text over secure
+// connections and encrypted with the public key of the server otherwise.
+func (mc *mysqlConn) handleCachingSHA2MoreData(status byte, authData []byte) error {
+	switch status {
+	case cachingSHA2FastAuthSuccess:
+		// an OK packet follows
+		return nil
+
+	case cachingSHA2PerformFullAuth:
+		if mc.cfg.tls != nil || mc.cfg.net == "unix" {
+			return mc.writeAuthSwitchPacket(append([]byte(mc.cfg.passwd), 0x00))
+		}
+
+		if err := mc.writeAuthSwitchPacket([]byte{cachingSHA2RequestPublicKey}); err != nil {
+			return err
+		}
+		data, err := mc.readPacket()
+		if err != nil {
+			return err
+		}
+		if data[0] != iAuthMoreData {
+			return mc.handleErrorPacket(data)
+		}
+
+		block, _ := pem.Decode(data[1:])
+		if block == nil {
+			return errPublicKey
+		}
+		pkix, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		pub, ok := pkix.(*rsa.PublicKey)
+		if !ok {
+			return errPublicKey
+		}
+
+		enc, err := encryptPassword(mc.cfg.passwd, authData, pub)
+		if err != nil {
+			return err
+		}
+		return mc.writeAuthSwitchPacket(enc)
+
+	default:
+		return mc.malformedPacket()
+	}
+}
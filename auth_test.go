@@ -0,0 +1,319 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"sync"
+	"testing"
+)
+
+var testScramble = []byte{
+	0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x70, 0x01, 0x12, 0x23,
+	0x34, 0x45, 0x56, 0x67, 0x78, 0x09, 0x10, 0x21, 0x32, 0x43,
+}
+
+// packet frames the payload as a packet with the given sequence id
+func packet(seq byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append([]byte{byte(len(body)), byte(len(body) >> 8), byte(len(body) >> 16), seq}, body...)
+}
+
+func authSwitchRequest(seq byte, plugin string, authData []byte) []byte {
+	return packet(seq, []byte{iEOF}, []byte(plugin), []byte{0x00}, authData, []byte{0x00})
+}
+
+// writtenPackets splits the written data into payloads
+func writtenPackets(t *testing.T, b []byte) [][]byte {
+	var payloads [][]byte
+	for len(b) > 0 {
+		if len(b) < 4 {
+			t.Fatalf("truncated packet header %v", b)
+		}
+		n := int(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16)
+		if len(b) < 4+n {
+			t.Fatalf("truncated packet %v", b)
+		}
+		payloads = append(payloads, b[4:4+n])
+		b = b[4+n:]
+	}
+	return payloads
+}
+
+var okPayload = []byte{iOK, 0, 0, 2, 0, 0, 0}
+
+func TestChangeUserNativePassword(t *testing.T) {
+	mc, conn := newMockConn(clientPluginAuth)
+	mc.cfg.user = "gopher"
+	mc.cipher = make([]byte, 20)
+	stmt := &mysqlStmt{mc: mc, id: 1, generation: mc.generation}
+
+	conn.queued = append(conn.queued,
+		authSwitchRequest(1, authNativePassword, testScramble),
+		packet(3, okPayload),
+	)
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", "tenantdb"); err != nil {
+		t.Fatal(err)
+	}
+
+	written := writtenPackets(t, conn.written.Bytes())
+	if len(written) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(written))
+	}
+	expected := []byte{comChangeUser}
+	expected = append(expected, "tenant\x00"...)
+	expected = append(expected, 20)
+	expected = append(expected, scramblePassword(make([]byte, 20), []byte("secret"))...)
+	expected = append(expected, "tenantdb\x00"...)
+	expected = append(expected, collation_utf8_general_ci, 0)
+	expected = append(expected, authNativePassword+"\x00"...)
+	if !bytes.Equal(written[0], expected) {
+		t.Errorf("unexpected COM_CHANGE_USER packet %v", written[0])
+	}
+	if !bytes.Equal(written[1], scramblePassword(testScramble, []byte("secret"))) {
+		t.Errorf("unexpected auth switch response %v", written[1])
+	}
+
+	if mc.cfg.user != "tenant" || mc.cfg.passwd != "secret" || mc.cfg.dbname != "tenantdb" {
+		t.Errorf("config was not updated: %+v", mc.cfg)
+	}
+	if !bytes.Equal(mc.cipher, testScramble) {
+		t.Errorf("expected the new scramble to be saved, got %v", mc.cipher)
+	}
+	if mc.generation != stmt.generation+1 {
+		t.Errorf("expected statements to be invalidated")
+	}
+}
+
+func TestChangeUserRestoresDSNUser(t *testing.T) {
+	mc, conn := newMockConn(clientPluginAuth)
+	mc.cfg.user = "gopher"
+	mc.cipher = make([]byte, 20)
+	dsnCfg := mc.cfg
+
+	conn.queued = append(conn.queued, packet(1, okPayload))
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", "tenantdb"); err != nil {
+		t.Fatal(err)
+	}
+	if !mc.sessionModified {
+		t.Error("expected the session to be marked modified")
+	}
+	if dsnCfg.user != "gopher" {
+		t.Errorf("the config of the DSN was modified: %+v", dsnCfg)
+	}
+
+	// the user of the DSN is restored before reuse, even without resetSession
+	conn.written.Reset()
+	conn.queued = append(conn.queued, packet(1, okPayload))
+	if err := mc.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	written := writtenPackets(t, conn.written.Bytes())
+	if len(written) != 1 || written[0][0] != comChangeUser || !bytes.HasPrefix(written[0][1:], []byte("gopher\x00")) {
+		t.Errorf("expected COM_CHANGE_USER of the DSN user, got %v", written)
+	}
+	if mc.cfg != dsnCfg || mc.sessionModified {
+		t.Errorf("unexpected state after reset: user %s, modified %v", mc.cfg.user, mc.sessionModified)
+	}
+
+	// no further reset
+	conn.written.Reset()
+	if err := mc.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if conn.written.Len() != 0 {
+		t.Errorf("expected no reset, got %v", conn.written.Bytes())
+	}
+}
+
+func TestChangeUserCachingSHA2Password(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})
+
+	// fast authentication with cached credentials
+	mc, conn := newMockConn(clientPluginAuth)
+	conn.queued = append(conn.queued,
+		authSwitchRequest(1, authCachingSHA2Password, testScramble),
+		packet(3, []byte{iAuthMoreData, cachingSHA2FastAuthSuccess}),
+		packet(4, okPayload),
+	)
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	written := writtenPackets(t, conn.written.Bytes())
+	if len(written) != 2 || !bytes.Equal(written[1], scrambleSHA256Password(testScramble, []byte("secret"))) {
+		t.Errorf("unexpected auth switch response %v", written)
+	}
+
+	// full authentication with the public key of the server
+	mc, conn = newMockConn(clientPluginAuth)
+	mc.cfg.net = "tcp"
+	conn.queued = append(conn.queued,
+		authSwitchRequest(1, authCachingSHA2Password, testScramble),
+		packet(3, []byte{iAuthMoreData, cachingSHA2PerformFullAuth}),
+		packet(5, []byte{iAuthMoreData}, pubPEM),
+		packet(7, okPayload),
+	)
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	written = writtenPackets(t, conn.written.Bytes())
+	if len(written) != 4 {
+		t.Fatalf("expected 4 packets, got %d", len(written))
+	}
+	if !bytes.Equal(written[2], []byte{cachingSHA2RequestPublicKey}) {
+		t.Errorf("expected public key request, got %v", written[2])
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, written[3], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range plain {
+		plain[i] ^= testScramble[i%len(testScramble)]
+	}
+	if string(plain) != "secret\x00" {
+		t.Errorf("unexpected decrypted password %q", plain)
+	}
+
+	// full authentication over a secure connection
+	mc, conn = newMockConn(clientPluginAuth)
+	mc.cfg.net = "unix"
+	conn.queued = append(conn.queued,
+		authSwitchRequest(1, authCachingSHA2Password, testScramble),
+		packet(3, []byte{iAuthMoreData, cachingSHA2PerformFullAuth}),
+		packet(5, okPayload),
+	)
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	written = writtenPackets(t, conn.written.Bytes())
+	if len(written) != 3 || string(written[2]) != "secret\x00" {
+		t.Errorf("expected the plain password, got %v", written)
+	}
+}
+
+func TestConnectAuthSwitch(t *testing.T) {
+	connector, err := NewConnector("gopher:secret@tcp(server:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fake switches every user to caching_sha2_password
+	switchScramble := bytes.Repeat([]byte{0x5a}, 20)
+	passwords := map[string]string{"gopher": "secret", "tenant": "tenantsecret"}
+	var mu sync.Mutex
+	var logins []string
+	var user string
+	scramble := testScramble
+	connector.Dialer = fakeAuthDialer(func(name, plugin string, authResp []byte) [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if plugin != "" {
+			user = name
+			logins = append(logins, name+" "+plugin)
+			if !bytes.Equal(authResp, scramblePassword(scramble, []byte(passwords[name]))) {
+				return [][]byte{append([]byte{iERR, 0x15, 0x04, '#'}, "28000Access denied"...)}
+			}
+			scramble = switchScramble
+			return [][]byte{authSwitchRequest(0, authCachingSHA2Password, switchScramble)[4:]}
+		}
+		if !bytes.Equal(authResp, scrambleSHA256Password(switchScramble, []byte(passwords[user]))) {
+			return [][]byte{append([]byte{iERR, 0x15, 0x04, '#'}, "28000Access denied"...)}
+		}
+		return [][]byte{{iAuthMoreData, cachingSHA2FastAuthSuccess}, okPayload}
+	}, nil)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// COM_CHANGE_USER uses the new scramble of the auth switch
+	err = conn.Raw(func(driverConn interface{}) error {
+		mc := driverConn.(*mysqlConn)
+		if mc.flags&clientPluginAuth == 0 {
+			t.Error("expected CLIENT_PLUGIN_AUTH to be negotiated")
+		}
+		return mc.ChangeUser(ctx, "tenant", "tenantsecret", "")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logins) != 2 || logins[0] != "gopher mysql_native_password" || logins[1] != "tenant mysql_native_password" {
+		t.Errorf("unexpected logins %v", logins)
+	}
+}
+
+func TestChangeUserFailure(t *testing.T) {
+	// access denied
+	mc, conn := newMockConn(clientPluginAuth)
+	conn.queued = append(conn.queued,
+		packet(1, []byte{iERR, 0x15, 0x04, '#'}, []byte("28000Access denied")),
+	)
+	err := mc.ChangeUser(context.Background(), "tenant", "wrong", "")
+	if me, ok := err.(*MySQLError); !ok || me.Number != 1045 {
+		t.Errorf("expected access denied error, got %v", err)
+	}
+	if mc.IsValid() {
+		t.Error("expected the connection to be closed")
+	}
+
+	// old passwords are not allowed
+	mc, conn = newMockConn(clientPluginAuth)
+	conn.queued = append(conn.queued, authSwitchRequest(1, authOldPassword, testScramble[:8]))
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", ""); err != errOldPassword {
+		t.Errorf("expected errOldPassword, got %v", err)
+	}
+
+	// unknown auth plugin
+	mc, conn = newMockConn(clientPluginAuth)
+	conn.queued = append(conn.queued, authSwitchRequest(1, "dialog", nil))
+	if err := mc.ChangeUser(context.Background(), "tenant", "secret", ""); err == nil {
+		t.Error("expected error for unsupported auth plugin")
+	}
+}
+
+func TestAuthOldPasswordSwitch(t *testing.T) {
+	mc, conn := newMockConn(0)
+	mc.cfg.passwd = "secret"
+	mc.cfg.allowOldPasswords = true
+
+	// Old Authentication Method Switch Request without plugin auth
+	conn.queued = append(conn.queued, packet(2, []byte{iEOF}), packet(4, okPayload))
+	mc.sequence = 2
+	if err := mc.handleAuthResult(authNativePassword, testScramble); err != nil {
+		t.Fatal(err)
+	}
+	expected := append(scrambleOldPassword(testScramble, []byte("secret")), 0x00)
+	if written := writtenPackets(t, conn.written.Bytes()); len(written) != 1 || !bytes.Equal(written[0], expected) {
+		t.Errorf("unexpected old password response %v", written)
+	}
+}
@@ -0,0 +1,90 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || illumos
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris illumos
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// returns both ends of a TCP connection
+func tcpPipe(t *testing.T) (client, server net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen: %s", err.Error())
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	return client, server
+}
+
+func TestConnCheck(t *testing.T) {
+	client, server := tcpPipe(t)
+	defer client.Close()
+
+	if err := connCheck(client); err != nil {
+		t.Errorf("expected open connection, got %v", err)
+	}
+
+	server.Write([]byte{0x01})
+	time.Sleep(50 * time.Millisecond)
+	if err := connCheck(client); err != errUnreadData {
+		t.Errorf("expected errUnreadData, got %v", err)
+	}
+
+	server.Close()
+	time.Sleep(50 * time.Millisecond)
+	if err := connCheck(client); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestResetSessionClosedByServer(t *testing.T) {
+	client, server := tcpPipe(t)
+
+	mc := &mysqlConn{
+		buf:     newBuffer(client),
+		netConn: client,
+		rawConn: client,
+		cfg:     &config{checkConnLiveness: true},
+	}
+	if err := mc.ResetSession(context.Background()); err != nil {
+		t.Errorf("expected valid connection, got %v", err)
+	}
+
+	server.Close()
+	time.Sleep(50 * time.Millisecond)
+	if err := mc.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn, got %v", err)
+	}
+	if mc.IsValid() {
+		t.Error("expected the connection to be invalid")
+	}
+}
@@ -9,26 +9,111 @@
 package mysql
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql/driver"
 	"errors"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Conn provides driver-specific operations and information of a connection.
+// The driver connection of sql.Conn.Raw implements it:
+//
+//  err := conn.Raw(func(driverConn interface{}) error {
+//  	mc := driverConn.(mysql.Conn)
+//  	if mc.InTransaction() {
+//  ...
+type Conn interface {
+	// ConnectionID returns the id of the connection on the server, which
+	// is also shown by SHOW PROCESSLIST.
+	ConnectionID() uint32
+
+	// ServerInfo returns the information sent by the server in the
+	// handshake.
+	ServerInfo() ServerInfo
+
+	// ServerVersion returns the version sent by the server in the
+	// handshake, e.g. "8.0.32".
+	ServerVersion() string
+
+	// Capabilities returns the capability flags negotiated with the
+	// server.
+	// http://dev.mysql.com/doc/internals/en/capability-flags.html
+	Capabilities() uint32
+
+	// Charset returns the character set of the connection.
+	Charset() string
+
+	// LastResult returns the result of the last OK packet received.
+	LastResult() Result
+
+	// Status returns the server status flags of the last OK or EOF
+	// packet received.
+	Status() StatusFlag
+
+	// InTransaction reports whether a transaction is active.
+	InTransaction() bool
+
+	// Autocommit reports whether autocommit is enabled.
+	Autocommit() bool
+
+	// WarningCount returns the number of warnings generated by the last
+	// statement.
+	WarningCount() uint16
+
+	// Warnings retrieves the warnings generated by the last statement.
+	Warnings() (MySQLWarnings, error)
+
+	// SessionState returns the session state changes reported for the
+	// last statement executed with Exec.
+	SessionState() *SessionState
+
+	// Ping checks that the server is still alive with COM_PING.
+	Ping(ctx context.Context) error
+
+	// InitDB changes the default database with COM_INIT_DB.
+	InitDB(ctx context.Context, dbname string) error
+
+	// Statistics returns the status string of COM_STATISTICS, e.g.
+	// "Uptime: 3600  Threads: 1  Questions: 42 ...".
+	Statistics(ctx context.Context) (string, error)
+
+	// Kill terminates the connection with the given id.
+	Kill(ctx context.Context, connectionID uint32) error
+
+	// ChangeUser changes the user and the default database with
+	// COM_CHANGE_USER.
+	ChangeUser(ctx context.Context, user, password, dbname string) error
+}
+
 type mysqlConn struct {
 	buf              *buffer
 	netConn          net.Conn
+	rawConn          net.Conn // underlying connection when netConn is a TLS connection
 	affectedRows     uint64
 	insertId         uint64
 	cfg              *config
+	dsnCfg           *config // config of the DSN after ChangeUser replaced cfg
 	maxPacketAllowed int
 	maxWriteSize     int
 	flags            clientFlag
 	sequence         uint8
 	parseTime        bool
-	strict           bool
+	warningCount     uint16
+	status           statusFlag
+	info             string
+	sessionState     *SessionState
+	sessionModified  bool
+	queryAttributes  []queryAttribute
+	cipher           []byte // scramble of the handshake, used by COM_CHANGE_USER
+	generation       uint32 // incremented by each session reset
+	noResetConn      bool   // server doesn't support COM_RESET_CONNECTION
+	serverInfo       ServerInfo
+	charset          string
+	hosts            *hostList // hosts of a multi-host DSN
 }
 
 type config struct {
@@ -44,10 +129,32 @@ type config struct {
 	allowAllFiles     bool
 	allowOldPasswords bool
 	clientFoundRows   bool
+	zeroDate          zeroDateMode
+	serverTimeZone    serverTimeZoneMode
+	warnings          warningsMode
+	checkConnLiveness bool
+	resetSession      bool
+	addrs             []string // all hosts of a multi-host DSN, addr is the current one
+	hostPolicy        hostPolicy
+	hostBackoff       time.Duration
+	requireWritable   bool
 }
 
+// serverTimeZoneMode controls how the session time_zone of the server and
+// the loc parameter are synchronized, see the DSN parameter serverTimeZone.
+type serverTimeZoneMode uint8
+
+const (
+	serverTimeZoneOff  serverTimeZoneMode = iota
+	serverTimeZoneSync                    // SET time_zone to match loc
+	serverTimeZoneRead                    // set loc to the server's time_zone
+)
+
 // Handles parameters set in DSN
 func (mc *mysqlConn) handleParams() (err error) {
+	// The session starts with the charset of the handshake
+	mc.charset = defaultCharset
+
 	for param, val := range mc.cfg.params {
 		switch param {
 		// Charset
@@ -57,6 +164,7 @@ func (mc *mysqlConn) handleParams() (err error) {
 				// ignore errors here - a charset may not exist
 				err = mc.exec("SET NAMES " + charsets[i])
 				if err == nil {
+					mc.charset = charsets[i]
 					break
 				}
 			}
@@ -72,14 +180,6 @@ func (mc *mysqlConn) handleParams() (err error) {
 				return errors.New("Invalid Bool value: " + val)
 			}
 
-		// Strict mode
-		case "strict":
-			var isBool bool
-			mc.strict, isBool = readBool(val)
-			if !isBool {
-				return errors.New("Invalid Bool value: " + val)
-			}
-
 		// Compression
 		case "compress":
 			err = errors.New("Compression not implemented yet")
@@ -97,6 +197,60 @@ func (mc *mysqlConn) handleParams() (err error) {
 	return
 }
 
+// Synchronizes the session time_zone and the location used for time.Time
+// values according to the serverTimeZone DSN parameter
+func (mc *mysqlConn) handleServerTimeZone() error {
+	switch mc.cfg.serverTimeZone {
+	case serverTimeZoneSync:
+		// Try the name of the location first. This only works if the time
+		// zone tables of the server are loaded.
+		if name := mc.cfg.loc.String(); mc.cfg.loc != time.UTC && mc.cfg.loc != time.Local {
+			err := mc.exec("SET time_zone='" + strings.Replace(name, "'", "''", -1) + "'")
+			if !hasErrorNumber(err, ER_UNKNOWN_TIME_ZONE) {
+				return err
+			}
+		}
+
+		// Fall back to the current UTC offset of the location
+		return mc.exec("SET time_zone='" + formatUTCOffset(time.Now().In(mc.cfg.loc)) + "'")
+
+	case serverTimeZoneRead:
+		tz, err := mc.getSystemVar("session.time_zone")
+		if err != nil {
+			return err
+		}
+		name := string(tz)
+		if name == "SYSTEM" {
+			if tz, err = mc.getSystemVar("system_time_zone"); err != nil {
+				return err
+			}
+			name = string(tz)
+		}
+
+		if loc, ok := parseUTCOffset(name); ok {
+			mc.cfg.loc = loc
+			return nil
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			mc.cfg.loc = loc
+			return nil
+		}
+
+		// The name is unknown to Go (e.g. an abbreviation like "CEST" used by
+		// system_time_zone). Use the current offset of the server instead.
+		offset, err := mc.queryValue("SELECT TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())")
+		if err != nil {
+			return err
+		}
+		seconds, err := strconv.Atoi(string(offset))
+		if err != nil {
+			return err
+		}
+		mc.cfg.loc = time.FixedZone(name, seconds)
+	}
+	return nil
+}
+
 func (mc *mysqlConn) Begin() (driver.Tx, error) {
 	if mc.netConn == nil {
 		errLog.Print(errInvalidConn)
@@ -104,12 +258,31 @@ func (mc *mysqlConn) Begin() (driver.Tx, error) {
 	}
 	err := mc.exec("START TRANSACTION")
 	if err == nil {
-		return &mysqlTx{mc}, err
+		return &mysqlTx{mc: mc}, err
 	}
 
 	return nil, err
 }
 
+// BeginTx implements the driver.ConnBeginTx interface. The context is only
+// checked for cancellation and used for CaptureGTIDs, isolation levels and
+// read-only transactions are not supported.
+func (mc *mysqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// zero is sql.LevelDefault
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errTxOptions
+	}
+	tx, err := mc.Begin()
+	if err != nil {
+		return nil, err
+	}
+	tx.(*mysqlTx).gtids = gtidToken(ctx)
+	return tx, nil
+}
+
 func (mc *mysqlConn) Close() (err error) {
 	// Makes Close idempotent
 	if mc.netConn != nil {
@@ -124,11 +297,334 @@ func (mc *mysqlConn) Close() (err error) {
 	return
 }
 
+// Ping implements the driver.Pinger interface.
+// It sends COM_PING to check that the server is still alive.
+func (mc *mysqlConn) Ping(ctx context.Context) error {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return driver.ErrBadConn
+	}
+
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	defer clearDeadline()
+
+	if err := mc.writeCommandPacket(comPing); err != nil {
+		return err
+	}
+	return mc.readResultOK()
+}
+
+// Applies the deadline of ctx to the network connection. The returned
+// function removes it again.
+func (mc *mysqlConn) setDeadline(ctx context.Context) (func(), error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return func() {}, nil
+	}
+
+	netConn := mc.netConn
+	if err := netConn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	return func() { netConn.SetDeadline(time.Time{}) }, nil
+}
+
+// ConnectionID implements the Conn interface.
+func (mc *mysqlConn) ConnectionID() uint32 {
+	return mc.serverInfo.ConnectionID
+}
+
+// ServerInfo implements the Conn interface.
+func (mc *mysqlConn) ServerInfo() ServerInfo {
+	return mc.serverInfo
+}
+
+// ServerVersion implements the Conn interface.
+func (mc *mysqlConn) ServerVersion() string {
+	return mc.serverInfo.Version
+}
+
+// Capabilities implements the Conn interface.
+func (mc *mysqlConn) Capabilities() uint32 {
+	return uint32(mc.flags)
+}
+
+// Charset implements the Conn interface.
+func (mc *mysqlConn) Charset() string {
+	return mc.charset
+}
+
+// LastResult implements the Conn interface.
+func (mc *mysqlConn) LastResult() Result {
+	return &mysqlResult{
+		affectedRows: int64(mc.affectedRows),
+		insertId:     int64(mc.insertId),
+		warningCount: mc.warningCount,
+		status:       mc.status,
+		info:         mc.info,
+	}
+}
+
+// Status implements the Conn interface.
+func (mc *mysqlConn) Status() StatusFlag {
+	return StatusFlag(mc.status)
+}
+
+// InTransaction implements the Conn interface.
+func (mc *mysqlConn) InTransaction() bool {
+	return mc.status&statusInTrans != 0
+}
+
+// Autocommit implements the Conn interface.
+func (mc *mysqlConn) Autocommit() bool {
+	return mc.status&statusInAutocommit != 0
+}
+
+// InitDB implements the Conn interface. Like USE, it only changes the
+// session, the database of the DSN is selected again when the session is
+// reset (see the DSN parameter resetSession).
+func (mc *mysqlConn) InitDB(ctx context.Context, dbname string) error {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return driver.ErrBadConn
+	}
+
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	defer clearDeadline()
+
+	if err = mc.writeCommandPacketStr(comInitDB, dbname); err != nil {
+		return err
+	}
+	if err = mc.readResultOK(); err != nil {
+		return err
+	}
+	mc.sessionModified = true
+	return nil
+}
+
+// Statistics implements the Conn interface.
+func (mc *mysqlConn) Statistics(ctx context.Context) (string, error) {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return "", driver.ErrBadConn
+	}
+
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer clearDeadline()
+
+	if err = mc.writeCommandPacket(comStatistics); err != nil {
+		return "", err
+	}
+
+	// The response is a string without header, unless it's an error
+	data, err := mc.readPacket()
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 && data[0] == iERR {
+		return "", mc.handleErrorPacket(data)
+	}
+	return string(data), nil
+}
+
+// Kill implements the Conn interface. It sends COM_PROCESS_KILL or a KILL
+// statement, if the server doesn't support the command anymore (MySQL 8.0+).
+func (mc *mysqlConn) Kill(ctx context.Context, connectionID uint32) error {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return driver.ErrBadConn
+	}
+
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	defer clearDeadline()
+
+	if err = mc.writeCommandPacketUint32(comProcessKill, connectionID); err != nil {
+		return err
+	}
+	err = mc.readResultOK()
+	if hasErrorNumber(err, ER_UNKNOWN_COM_ERROR) {
+		err = mc.exec("KILL " + strconv.FormatUint(uint64(connectionID), 10))
+	}
+	return err
+}
+
+// IsValid implements the driver.Validator interface.
+// A connection is invalid after it was closed, e.g. because of a broken
+// network connection.
+func (mc *mysqlConn) IsValid() bool {
+	return mc.netConn != nil
+}
+
+// ResetSession implements the driver.SessionResetter interface.
+// It is called before a connection is reused and checks whether the server
+// closed the connection in the meantime (see the DSN parameter
+// checkConnLiveness).
+func (mc *mysqlConn) ResetSession(ctx context.Context) error {
+	if mc.netConn == nil {
+		return driver.ErrBadConn
+	}
+
+	if mc.cfg.checkConnLiveness {
+		// Unread data in the buffer means the connection is out of sync
+		err := errUnreadData
+		if mc.buf.length == 0 {
+			err = connCheck(mc.rawConn)
+		}
+		if err != nil {
+			errLog.Print("closing bad idle connection: ", err)
+			mc.Close()
+			return driver.ErrBadConn
+		}
+	}
+
+	if mc.cfg.resetSession || mc.userChanged() {
+		if err := mc.resetSession(); err != nil {
+			errLog.Print("closing connection after failed session reset: ", err)
+			mc.Close()
+			return driver.ErrBadConn
+		}
+	}
+	return nil
+}
+
+// Resets the session state to the state after connecting: user variables,
+// temporary tables, session variables, the default database and prepared
+// statements. Uses COM_RESET_CONNECTION (MySQL 5.7.3+) and falls back to
+// COM_CHANGE_USER on older servers. COM_RESET_CONNECTION keeps the default
+// database, so the database of the DSN is selected again with COM_INIT_DB.
+// The DSN params are applied again. After ChangeUser, the user of the DSN is
+// restored with COM_CHANGE_USER.
+func (mc *mysqlConn) resetSession() error {
+	var err error
+	if mc.userChanged() {
+		mc.cfg = mc.dsnCfg
+		err = mc.changeUser()
+	} else {
+		if !mc.noResetConn {
+			if err = mc.writeCommandPacket(comResetConnection); err != nil {
+				return err
+			}
+			err = mc.readResultOK()
+			if hasErrorNumber(err, ER_UNKNOWN_COM_ERROR) {
+				mc.noResetConn = true
+			} else if err == nil && mc.cfg.dbname != "" {
+				if err = mc.writeCommandPacketStr(comInitDB, mc.cfg.dbname); err != nil {
+					return err
+				}
+				err = mc.readResultOK()
+			}
+		}
+
+		if mc.noResetConn {
+			err = mc.changeUser()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return mc.restoreSession()
+}
+
+// ChangeUser changes the user and the default database of the connection with
+// COM_CHANGE_USER, without opening a new network connection:
+//
+//  err := conn.Raw(func(driverConn interface{}) error {
+//  	return driverConn.(mysql.Conn).ChangeUser(ctx, "tenant", "secret", "tenantdb")
+//  })
+//
+// The server resets the session like for a new connection, prepared
+// statements are closed and the DSN params are applied again. If the change
+// fails, the connection is closed. Before the connection is reused from the
+// pool, the user of the DSN is restored, independent of resetSession.
+func (mc *mysqlConn) ChangeUser(ctx context.Context, user, password, dbname string) error {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return driver.ErrBadConn
+	}
+
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return err
+	}
+	defer clearDeadline()
+
+	// The config may be shared with other connections, the config of the
+	// DSN is kept to restore its user
+	if mc.dsnCfg == nil {
+		mc.dsnCfg = mc.cfg
+	}
+	cfg := *mc.dsnCfg
+	cfg.user, cfg.passwd, cfg.dbname = user, password, dbname
+	mc.cfg = &cfg
+
+	err = mc.changeUser()
+	if err == nil {
+		err = mc.restoreSession()
+	}
+	if err != nil {
+		mc.Close()
+		return err
+	}
+	mc.sessionModified = true
+	return nil
+}
+
+// Reports whether ChangeUser replaced the user of the DSN
+func (mc *mysqlConn) userChanged() bool {
+	return mc.dsnCfg != nil && mc.cfg != mc.dsnCfg
+}
+
+// Authenticates again with the credentials of the config, which also resets
+// the session
+func (mc *mysqlConn) changeUser() error {
+	if err := mc.writeChangeUserPacket(mc.cipher); err != nil {
+		return err
+	}
+	return mc.handleAuthResult(authNativePassword, mc.cipher)
+}
+
+// Restores the initial state of the connection after the server reset the
+// session
+func (mc *mysqlConn) restoreSession() error {
+	// All prepared statements were closed by the server
+	mc.generation++
+
+	if err := mc.handleParams(); err != nil {
+		return err
+	}
+	if err := mc.handleServerTimeZone(); err != nil {
+		return err
+	}
+	mc.sessionModified = false
+	return nil
+}
+
 func (mc *mysqlConn) Prepare(query string) (driver.Stmt, error) {
 	if mc.netConn == nil {
 		errLog.Print(errInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+
+	stmt, err := mc.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (mc *mysqlConn) prepare(query string) (*mysqlStmt, error) {
 	// Send command
 	err := mc.writeCommandPacketStr(comStmtPrepare, query)
 	if err != nil {
@@ -136,20 +632,34 @@ func (mc *mysqlConn) Prepare(query string) (driver.Stmt, error) {
 	}
 
 	stmt := &mysqlStmt{
-		mc: mc,
+		mc:         mc,
+		query:      query,
+		generation: mc.generation,
 	}
 
 	// Read Result
 	columnCount, err := stmt.readPrepareResultPacket()
 	if err == nil {
+		// The EOF packets of the definitions overwrite the warning count
+		warningCount := mc.warningCount
+
 		if stmt.paramCount > 0 {
-			if err = mc.readUntilEOF(); err != nil {
+			if err = mc.skipColumns(stmt.paramCount); err != nil {
 				return nil, err
 			}
 		}
 
 		if columnCount > 0 {
-			err = mc.readUntilEOF()
+			if err = mc.skipColumns(int(columnCount)); err != nil {
+				return nil, err
+			}
+		}
+
+		// The whole response must be read before SHOW WARNINGS is sent
+		mc.warningCount = warningCount
+		if err = mc.handleWarnings(); err != nil {
+			stmt.Close()
+			return nil, err
 		}
 	}
 
@@ -164,12 +674,18 @@ func (mc *mysqlConn) Exec(query string, args []driver.Value) (driver.Result, err
 	if len(args) == 0 { // no args, fastpath
 		mc.affectedRows = 0
 		mc.insertId = 0
+		mc.warningCount = 0
+		mc.info = ""
+		mc.sessionState = nil
 
 		err := mc.exec(query)
 		if err == nil {
 			return &mysqlResult{
 				affectedRows: int64(mc.affectedRows),
 				insertId:     int64(mc.insertId),
+				warningCount: mc.warningCount,
+				status:       mc.status,
+				info:         mc.info,
 			}, err
 		}
 		return nil, err
@@ -191,7 +707,7 @@ func (mc *mysqlConn) exec(query string) error {
 	// Read Result
 	resLen, err := mc.readResultSetHeaderPacket()
 	if err == nil && resLen > 0 {
-		if err = mc.readUntilEOF(); err != nil {
+		if err = mc.skipColumns(resLen); err != nil {
 			return err
 		}
 
@@ -231,11 +747,49 @@ func (mc *mysqlConn) Query(query string, args []driver.Value) (driver.Rows, erro
 	return nil, driver.ErrSkip
 }
 
+// ExecContext implements the driver.ExecerContext interface.
+// The context is only used for query attributes and CaptureGTIDs.
+func (mc *mysqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	dargs, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = mc.setQueryAttributes(ctx); err != nil {
+		return nil, err
+	}
+	defer mc.setQueryAttributes(context.Background())
+
+	res, err := mc.Exec(query, dargs)
+	if err == nil {
+		mc.captureGTIDs(gtidToken(ctx))
+	}
+	return res, err
+}
+
+// QueryContext implements the driver.QueryerContext interface.
+// The context is only used for query attributes.
+func (mc *mysqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	dargs, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = mc.setQueryAttributes(ctx); err != nil {
+		return nil, err
+	}
+	defer mc.setQueryAttributes(context.Background())
+
+	return mc.Query(query, dargs)
+}
+
 // Gets the value of the given MySQL System Variable
-// The returned byte slice is only valid until the next read
 func (mc *mysqlConn) getSystemVar(name string) ([]byte, error) {
+	return mc.queryValue("SELECT @@" + name)
+}
+
+// Returns the first column of the first row of the query result
+func (mc *mysqlConn) queryValue(query string) ([]byte, error) {
 	// Send command
-	if err := mc.writeCommandPacketStr(comQuery, "SELECT @@"+name); err != nil {
+	if err := mc.writeCommandPacketStr(comQuery, query); err != nil {
 		return nil, err
 	}
 
@@ -247,15 +801,24 @@ func (mc *mysqlConn) getSystemVar(name string) ([]byte, error) {
 
 		if resLen > 0 {
 			// Columns
-			if err := mc.readUntilEOF(); err != nil {
+			if err := mc.skipColumns(resLen); err != nil {
 				return nil, err
 			}
 		}
 
 		dest := make([]driver.Value, resLen)
 		if err = rows.readRow(dest); err == nil {
-			return dest[0].([]byte), mc.readUntilEOF()
+			// Copy the value, reading the EOF packet may overwrite the buffer
+			value, _ := dest[0].([]byte)
+			return append([]byte(nil), value...), mc.readUntilEOF()
 		}
 	}
 	return nil, err
 }
+
+// CheckNamedValue implements the driver.NamedValueChecker interface.
+// It is used instead of the default conversion to support uint64 parameters.
+func (mc *mysqlConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
+	nv.Value, err = converter{}.ConvertValue(nv.Value)
+	return
+}
@@ -0,0 +1,95 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+var _ Conn = &mysqlConn{}
+
+func TestConnStatus(t *testing.T) {
+	mc, conn := newMockConn(0)
+
+	// OK packet: 1 affected row, insert id 5, in transaction
+	conn.read.Write(packet(0, []byte{iOK, 1, 5, byte(statusInTrans), 0, 0, 0}))
+	if err := mc.readResultOK(); err != nil {
+		t.Fatal(err)
+	}
+	if !mc.InTransaction() || mc.Autocommit() {
+		t.Errorf("unexpected status %v", mc.Status())
+	}
+	res := mc.LastResult()
+	if res.AffectedRows() != 1 || res.LastInsertID() != 5 || res.Status() != StatusInTrans {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestConnCommands(t *testing.T) {
+	ctx := context.Background()
+	mc, conn := newMockConn(0)
+	mc.cfg.dbname = "gotest"
+	mc.cfg.resetSession = true
+
+	conn.read.Write(packet(1, okPayload))
+	if err := mc.InitDB(ctx, "tenantdb"); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written, packet(0, []byte{comInitDB}, []byte("tenantdb"))) {
+		t.Errorf("expected COM_INIT_DB, got %v", written)
+	}
+	if mc.cfg.dbname != "gotest" || !mc.sessionModified {
+		t.Errorf("unexpected state after COM_INIT_DB: dbname %s, modified %v", mc.cfg.dbname, mc.sessionModified)
+	}
+
+	// the reset selects the database of the DSN again
+	conn.written.Reset()
+	conn.read.Write(packet(1, okPayload))
+	conn.queued = append(conn.queued, packet(1, okPayload))
+	if err := mc.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	written := writtenPackets(t, conn.written.Bytes())
+	if len(written) != 2 || written[0][0] != comResetConnection || !bytes.Equal(written[1], []byte("\x02gotest")) {
+		t.Errorf("expected COM_RESET_CONNECTION and COM_INIT_DB gotest, got %v", written)
+	}
+
+	conn.written.Reset()
+	stats := "Uptime: 3600  Threads: 1  Questions: 42"
+	conn.read.Write(packet(1, []byte(stats)))
+	if res, err := mc.Statistics(ctx); err != nil || res != stats {
+		t.Errorf("unexpected statistics %q, %v", res, err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written, packet(0, []byte{comStatistics})) {
+		t.Errorf("expected COM_STATISTICS, got %v", written)
+	}
+
+	conn.written.Reset()
+	conn.read.Write(packet(1, okPayload))
+	if err := mc.Kill(ctx, 298); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written, packet(0, []byte{comProcessKill, 0x2a, 0x01, 0, 0})) {
+		t.Errorf("expected COM_PROCESS_KILL, got %v", written)
+	}
+
+	// fall back to KILL
+	conn.written.Reset()
+	conn.read.Write(packet(1, []byte{iERR, 0x17, 0x04, 'n', 'o'})) // ER_UNKNOWN_COM_ERROR
+	conn.queued = append(conn.queued, packet(1, okPayload))
+	if err := mc.Kill(ctx, 298); err != nil {
+		t.Fatal(err)
+	}
+	written = writtenPackets(t, conn.written.Bytes())
+	if len(written) != 2 || !bytes.Equal(written[1], []byte("\x03KILL 298")) {
+		t.Errorf("expected KILL statement, got %v", written)
+	}
+}
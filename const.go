@@ -24,6 +24,11 @@ const (
 	iERR         byte = 0xff
 )
 
+// COM_STMT_EXECUTE flags
+const (
+	cursorParameterCountAvailable byte = 0x08
+)
+
 type clientFlag uint32
 
 const (
@@ -45,6 +50,16 @@ const (
 	clientSecureConn
 	clientMultiStatements
 	clientMultiResults
+	clientPSMultiResults
+	clientPluginAuth
+	clientConnectAttrs
+	clientPluginAuthLenEncClientData
+	clientCanHandleExpiredPasswords
+	clientSessionTrack
+	clientDeprecateEOF
+	clientOptionalResultsetMetadata
+	clientZstdCompressionAlgorithm
+	clientQueryAttributes
 )
 
 const (
@@ -76,6 +91,9 @@ const (
 	comStmtReset
 	comSetOption
 	comStmtFetch
+	comDaemon
+	comBinlogDumpGTID
+	comResetConnection
 )
 
 const (
@@ -131,6 +149,27 @@ const (
 	flagUnknown4
 )
 
+// http://dev.mysql.com/doc/internals/en/status-flags.html
+type statusFlag uint16
+
+const (
+	statusInTrans statusFlag = 1 << iota
+	statusInAutocommit
+	statusReserved // Not in documentation
+	statusMoreResultsExists
+	statusNoGoodIndexUsed
+	statusNoIndexUsed
+	statusCursorExists
+	statusLastRowSent
+	statusDbDropped
+	statusNoBackslashEscapes
+	statusMetadataChanged
+	statusQueryWasSlow
+	statusPsOutParams
+	statusInTransReadonly
+	statusSessionStateChanged
+)
+
 const (
 	collation_ascii_general_ci   byte = 11
 	collation_utf8_general_ci    byte = 33
@@ -140,3 +179,6 @@ const (
 	collation_binary             byte = 63
 	collation_utf8mb4_unicode_ci byte = 224
 )
+
+// Charset of collation_utf8_general_ci, which is sent in the handshake
+const defaultCharset = "utf8"
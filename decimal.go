@@ -0,0 +1,184 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var bigTen = big.NewInt(10)
+
+// Decimal is an exact decimal number as stored in MySQL DECIMAL / NUMERIC
+// columns. It consists of an arbitrary precision unscaled integer and a
+// scale, so the represented number is unscaled * 10^-scale.
+// Decimal values never pass through a float64.
+//
+// Decimal implements the Scanner interface so it can be used as scan
+// destination. When used as parameter, it is sent as MYSQL_TYPE_NEWDECIMAL:
+//
+//  price, _ := mysql.ParseDecimal("19.99")
+//  _, err := db.Exec("INSERT INTO items (price) VALUES (?)", price)
+//  ...
+//  var d mysql.Decimal
+//  err = db.QueryRow("SELECT price FROM items WHERE id=?", id).Scan(&d)
+//
+// The zero value is 0 with scale 0.
+type Decimal struct {
+	unscaled *big.Int // nil means 0
+	scale    int32
+}
+
+// NewDecimal returns the Decimal unscaled * 10^-scale.
+// unscaled is copied and may be modified afterwards.
+func NewDecimal(unscaled *big.Int, scale int32) Decimal {
+	if unscaled == nil {
+		return Decimal{scale: scale}
+	}
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a decimal string like "-123.4500".
+// The scale of the result is the number of digits after the decimal point,
+// trailing zeros are preserved.
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	if len(str) > 0 && (str[0] == '-' || str[0] == '+') {
+		str = str[1:]
+	}
+
+	var scale int32
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		scale = int32(len(str) - i - 1)
+		str = str[:i] + str[i+1:]
+	}
+
+	if len(str) == 0 {
+		return Decimal{}, fmt.Errorf("Invalid Decimal: %q", s)
+	}
+	for i := 0; i < len(str); i++ {
+		if str[i] < '0' || str[i] > '9' {
+			return Decimal{}, fmt.Errorf("Invalid Decimal: %q", s)
+		}
+	}
+
+	unscaled, _ := new(big.Int).SetString(str, 10)
+	if s[0] == '-' {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// Unscaled returns a copy of the unscaled integer value of d.
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	if d.unscaled == nil {
+		return 0
+	}
+	return d.unscaled.Sign()
+}
+
+// Cmp compares d and e numerically, independent of their scales.
+// It returns -1 if d < e, 0 if d == e and +1 if d > e.
+func (d Decimal) Cmp(e Decimal) int {
+	a, b := d.Unscaled(), e.Unscaled()
+	if d.scale < e.scale {
+		a.Mul(a, new(big.Int).Exp(bigTen, big.NewInt(int64(e.scale-d.scale)), nil))
+	} else if d.scale > e.scale {
+		b.Mul(b, new(big.Int).Exp(bigTen, big.NewInt(int64(d.scale-e.scale)), nil))
+	}
+	return a.Cmp(b)
+}
+
+// String returns the plain decimal representation of d, e.g. "-123.4500".
+func (d Decimal) String() string {
+	digits := d.Unscaled().String()
+
+	var sign string
+	if digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+
+	if d.scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-d.scale))
+	}
+
+	scale := int(d.scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// Scan implements the Scanner interface.
+// The value type must be string / []byte (decimal number), int64 or uint64,
+// otherwise Scan fails. Use NullDecimal for nullable columns.
+func (d *Decimal) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+		return
+	case string:
+		*d, err = ParseDecimal(v)
+		return
+	case int64:
+		*d = Decimal{unscaled: big.NewInt(v)}
+		return
+	case uint64:
+		*d = Decimal{unscaled: new(big.Int).SetUint64(v)}
+		return
+	}
+	return fmt.Errorf("Can't convert %T to Decimal", value)
+}
+
+// Value implements the driver Valuer interface.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// NullDecimal represents a Decimal that may be NULL.
+// NullDecimal implements the Scanner interface so
+// it can be used as a scan destination, similar to NullTime.
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool // Valid is true if Decimal is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (nd *NullDecimal) Scan(value interface{}) (err error) {
+	if value == nil {
+		nd.Decimal, nd.Valid = Decimal{}, false
+		return
+	}
+	err = nd.Decimal.Scan(value)
+	nd.Valid = (err == nil)
+	return
+}
+
+// Value implements the driver Valuer interface.
+func (nd NullDecimal) Value() (driver.Value, error) {
+	if !nd.Valid {
+		return nil, nil
+	}
+	return nd.Decimal.String(), nil
+}
@@ -0,0 +1,102 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	var decimalTests = []struct {
+		in       string
+		unscaled string
+		scale    int32
+		out      string
+	}{
+		{"0", "0", 0, "0"},
+		{"0.00", "0", 2, "0.00"},
+		{"19.99", "1999", 2, "19.99"},
+		{"-19.99", "-1999", 2, "-19.99"},
+		{"+19.99", "1999", 2, "19.99"},
+		{"-0.05", "-5", 2, "-0.05"},
+		{".5", "5", 1, "0.5"},
+		{"12.", "12", 0, "12"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890123456789", 9, "123456789012345678901234567890.123456789"},
+	}
+
+	for _, tst := range decimalTests {
+		d, err := ParseDecimal(tst.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tst.in, err.Error())
+			continue
+		}
+		if d.Unscaled().String() != tst.unscaled || d.Scale() != tst.scale {
+			t.Errorf("%q: expected %s scale %d, got %s scale %d", tst.in, tst.unscaled, tst.scale, d.Unscaled(), d.Scale())
+		}
+		if d.String() != tst.out {
+			t.Errorf("%q: expected %q, got %q", tst.in, tst.out, d.String())
+		}
+	}
+
+	for _, in := range []string{"", "-", ".", "1.2.3", "1e5", "abc", " 1"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestDecimalCmp(t *testing.T) {
+	a, _ := ParseDecimal("1.50")
+	b, _ := ParseDecimal("1.5")
+	c := NewDecimal(big.NewInt(-15), 1)
+	if a.Cmp(b) != 0 {
+		t.Errorf("expected %s == %s", a, b)
+	}
+	if c.Cmp(a) != -1 || a.Cmp(c) != 1 {
+		t.Errorf("expected %s < %s", c, a)
+	}
+	if (Decimal{}).Cmp(NewDecimal(nil, 3)) != 0 {
+		t.Error("expected zero values to be equal")
+	}
+	if NewDecimal(big.NewInt(5), -2).String() != "500" {
+		t.Errorf("expected 500, got %s", NewDecimal(big.NewInt(5), -2))
+	}
+}
+
+func TestScanNullDecimal(t *testing.T) {
+	var scanTests = []struct {
+		in    interface{}
+		error bool
+		valid bool
+		out   string
+	}{
+		{[]byte("12.34"), false, true, "12.34"},
+		{"-0.1", false, true, "-0.1"},
+		{int64(-42), false, true, "-42"},
+		{uint64(18446744073709551615), false, true, "18446744073709551615"},
+		{nil, false, false, "0"},
+		{1.5, true, false, "0"},
+		{"x", true, false, "0"},
+	}
+
+	var nd NullDecimal
+	for _, tst := range scanTests {
+		err := nd.Scan(tst.in)
+		if (err != nil) != tst.error {
+			t.Errorf("%v: expected error status %t, got %t", tst.in, tst.error, (err != nil))
+		}
+		if nd.Valid != tst.valid {
+			t.Errorf("%v: expected valid status %t, got %t", tst.in, tst.valid, nd.Valid)
+		}
+		if nd.Valid && nd.Decimal.String() != tst.out {
+			t.Errorf("%v: expected %s, got %s", tst.in, tst.out, nd.Decimal.String())
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+)
+
+// FieldType is the protocol type of a result set column.
+type FieldType byte
+
+// Field types as sent by the server
+const (
+	FieldTypeDecimal    = FieldType(fieldTypeDecimal)
+	FieldTypeTiny       = FieldType(fieldTypeTiny)
+	FieldTypeShort      = FieldType(fieldTypeShort)
+	FieldTypeLong       = FieldType(fieldTypeLong)
+	FieldTypeFloat      = FieldType(fieldTypeFloat)
+	FieldTypeDouble     = FieldType(fieldTypeDouble)
+	FieldTypeNULL       = FieldType(fieldTypeNULL)
+	FieldTypeTimestamp  = FieldType(fieldTypeTimestamp)
+	FieldTypeLongLong   = FieldType(fieldTypeLongLong)
+	FieldTypeInt24      = FieldType(fieldTypeInt24)
+	FieldTypeDate       = FieldType(fieldTypeDate)
+	FieldTypeTime       = FieldType(fieldTypeTime)
+	FieldTypeDateTime   = FieldType(fieldTypeDateTime)
+	FieldTypeYear       = FieldType(fieldTypeYear)
+	FieldTypeNewDate    = FieldType(fieldTypeNewDate)
+	FieldTypeVarChar    = FieldType(fieldTypeVarChar)
+	FieldTypeBit        = FieldType(fieldTypeBit)
+	FieldTypeNewDecimal = FieldType(fieldTypeNewDecimal)
+	FieldTypeEnum       = FieldType(fieldTypeEnum)
+	FieldTypeSet        = FieldType(fieldTypeSet)
+	FieldTypeTinyBLOB   = FieldType(fieldTypeTinyBLOB)
+	FieldTypeMediumBLOB = FieldType(fieldTypeMediumBLOB)
+	FieldTypeLongBLOB   = FieldType(fieldTypeLongBLOB)
+	FieldTypeBLOB       = FieldType(fieldTypeBLOB)
+	FieldTypeVarString  = FieldType(fieldTypeVarString)
+	FieldTypeString     = FieldType(fieldTypeString)
+	FieldTypeGeometry   = FieldType(fieldTypeGeometry)
+)
+
+// FieldFlag is the set of flags of a result set column.
+type FieldFlag uint16
+
+// Column flags as sent by the server
+const (
+	FlagNotNULL       = FieldFlag(flagNotNULL)
+	FlagPriKey        = FieldFlag(flagPriKey)
+	FlagUniqueKey     = FieldFlag(flagUniqueKey)
+	FlagMultipleKey   = FieldFlag(flagMultipleKey)
+	FlagBLOB          = FieldFlag(flagBLOB)
+	FlagUnsigned      = FieldFlag(flagUnsigned)
+	FlagZeroFill      = FieldFlag(flagZeroFill)
+	FlagBinary        = FieldFlag(flagBinary)
+	FlagEnum          = FieldFlag(flagEnum)
+	FlagAutoIncrement = FieldFlag(flagAutoIncrement)
+	FlagTimestamp     = FieldFlag(flagTimestamp)
+	FlagSet           = FieldFlag(flagSet)
+)
+
+// TypeDecoderKey selects the columns a TypeDecoder is used for.
+// Type must always match, the other fields only if they are non-zero.
+type TypeDecoderKey struct {
+	// Type is the protocol type of the column. It is always compared, so the
+	// zero value selects FieldTypeDecimal columns.
+	Type FieldType
+
+	// Flags must all be set on the column, e.g. FlagBinary.
+	Flags FieldFlag
+
+	// DatabaseTypeName is the SQL type name as reported by
+	// sql.ColumnType.DatabaseTypeName, e.g. "BINARY" or "TINYINT".
+	DatabaseTypeName string
+
+	// Length is the column length, e.g. 16 for BINARY(16) or 1 for
+	// TINYINT(1). Note that the length of character columns is given in
+	// bytes, not in characters.
+	Length uint32
+}
+
+func (key *TypeDecoderKey) matches(mf *mysqlField) bool {
+	return key.Type == FieldType(mf.fieldType) &&
+		FieldFlag(mf.flags)&key.Flags == key.Flags &&
+		(key.DatabaseTypeName == "" || key.DatabaseTypeName == mf.typeDatabaseName()) &&
+		(key.Length == 0 || key.Length == mf.length)
+}
+
+// TypeDecoder converts a non-NULL column value.
+//
+// src is the value as the driver would return it without a decoder, i.e.
+// a []byte for all columns of text protocol results (queries without
+// arguments) and an int64, uint64, float64 or []byte for binary protocol
+// results (prepared statements). DATE and DATETIME values are a time.Time
+// if parseTime=true.
+//
+// A []byte src is only valid until the decoder returns, it must be copied if
+// it is part of the returned value.
+type TypeDecoder func(src driver.Value) (driver.Value, error)
+
+type typeDecoder struct {
+	key     TypeDecoderKey
+	decoder TypeDecoder
+}
+
+var typeDecoderRegister []typeDecoder
+
+// RegisterTypeDecoder registers a decoder which is used for all result set
+// columns matching key. The decoder is applied while the row is decoded,
+// before database/sql converts the value to the scan destination:
+//
+//  mysql.RegisterTypeDecoder(mysql.TypeDecoderKey{
+//  	Type:   mysql.FieldTypeTiny,
+//  	Length: 1,
+//  }, mysql.DecodeBool)
+//
+// If multiple keys match a column, the decoder registered first is used.
+// Registering a decoder for an already registered key replaces it.
+// Decoders are looked up when the column metadata is read, so decoders should
+// be registered before connections are opened.
+func RegisterTypeDecoder(key TypeDecoderKey, decoder TypeDecoder) {
+	for i := range typeDecoderRegister {
+		if typeDecoderRegister[i].key == key {
+			typeDecoderRegister[i].decoder = decoder
+			return
+		}
+	}
+	typeDecoderRegister = append(typeDecoderRegister, typeDecoder{key, decoder})
+}
+
+// DeregisterTypeDecoder removes the decoder registered for key.
+func DeregisterTypeDecoder(key TypeDecoderKey) {
+	for i := range typeDecoderRegister {
+		if typeDecoderRegister[i].key == key {
+			typeDecoderRegister = append(typeDecoderRegister[:i], typeDecoderRegister[i+1:]...)
+			return
+		}
+	}
+}
+
+// lookupTypeDecoder returns the decoder for the given column or nil.
+func lookupTypeDecoder(mf *mysqlField) TypeDecoder {
+	for i := range typeDecoderRegister {
+		if typeDecoderRegister[i].key.matches(mf) {
+			return typeDecoderRegister[i].decoder
+		}
+	}
+	return nil
+}
+
+// applyDecoders converts all non-NULL values of the current row with the
+// decoders of their columns.
+func (rows *mysqlRows) applyDecoders(dest []driver.Value) (err error) {
+	for i := range rows.columns {
+		if decoder := rows.columns[i].decoder; decoder != nil && dest[i] != nil {
+			if dest[i], err = decoder(dest[i]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+/******************************************************************************
+*                              Common decoders                                *
+******************************************************************************/
+
+// DecodeBool decodes integer columns, e.g. TINYINT(1) / BOOL, to bool.
+// All values other than 0 are true.
+func DecodeBool(src driver.Value) (driver.Value, error) {
+	switch v := src.(type) {
+	case int64:
+		return v != 0, nil
+	case uint64:
+		return v != 0, nil
+	case []byte:
+		for _, c := range v {
+			if c != '0' && c != '-' {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("Can't convert %T to bool", src)
+}
+
+// DecodeUUID returns a decoder for BINARY(16) columns which contain UUIDs.
+// The UUID is returned in its canonical string form. If swapped is true, the
+// time-low and time-high parts are expected to be swapped, as done by
+// MySQL's UUID_TO_BIN(uuid, 1).
+//
+//  mysql.RegisterTypeDecoder(mysql.TypeDecoderKey{
+//  	Type:   mysql.FieldTypeString,
+//  	Flags:  mysql.FlagBinary,
+//  	Length: 16,
+//  }, mysql.DecodeUUID(false))
+func DecodeUUID(swapped bool) TypeDecoder {
+	return func(src driver.Value) (driver.Value, error) {
+		b, ok := src.([]byte)
+		if !ok || len(b) != 16 {
+			return nil, fmt.Errorf("Can't convert %T of length %d to UUID", src, len(b))
+		}
+
+		var u [16]byte
+		if swapped {
+			copy(u[0:4], b[4:8])
+			copy(u[4:6], b[2:4])
+			copy(u[6:8], b[0:2])
+			copy(u[8:], b[8:])
+		} else {
+			copy(u[:], b)
+		}
+
+		var dst [36]byte
+		hex.Encode(dst[0:8], u[0:4])
+		dst[8] = '-'
+		hex.Encode(dst[9:13], u[4:6])
+		dst[13] = '-'
+		hex.Encode(dst[14:18], u[6:8])
+		dst[18] = '-'
+		hex.Encode(dst[19:23], u[8:10])
+		dst[23] = '-'
+		hex.Encode(dst[24:], u[10:])
+		return string(dst[:]), nil
+	}
+}
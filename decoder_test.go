@@ -0,0 +1,115 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+var (
+	uuidKey = TypeDecoderKey{Type: FieldTypeString, Flags: FlagBinary, Length: 16}
+	boolKey = TypeDecoderKey{Type: FieldTypeTiny, DatabaseTypeName: "TINYINT", Length: 1}
+)
+
+func TestTypeDecoderLookup(t *testing.T) {
+	defer func() { typeDecoderRegister = nil }()
+	RegisterTypeDecoder(uuidKey, DecodeUUID(false))
+	RegisterTypeDecoder(boolKey, DecodeBool)
+
+	binary16 := mysqlField{fieldType: fieldTypeString, flags: flagBinary | flagNotNULL, charSet: uint16(collation_binary), length: 16}
+	binary8 := mysqlField{fieldType: fieldTypeString, flags: flagBinary, charSet: uint16(collation_binary), length: 8}
+	char16 := mysqlField{fieldType: fieldTypeString, length: 16}
+	tinyint1 := mysqlField{fieldType: fieldTypeTiny, length: 1}
+	tinyint4 := mysqlField{fieldType: fieldTypeTiny, length: 4}
+
+	if lookupTypeDecoder(&binary16) == nil {
+		t.Error("expected decoder for BINARY(16)")
+	}
+	if lookupTypeDecoder(&binary8) != nil || lookupTypeDecoder(&char16) != nil {
+		t.Error("unexpected decoder for BINARY(8) / CHAR(16)")
+	}
+	if lookupTypeDecoder(&tinyint1) == nil {
+		t.Error("expected decoder for TINYINT(1)")
+	}
+	if lookupTypeDecoder(&tinyint4) != nil {
+		t.Error("unexpected decoder for TINYINT(4)")
+	}
+
+	DeregisterTypeDecoder(boolKey)
+	if lookupTypeDecoder(&tinyint1) != nil {
+		t.Error("unexpected decoder for TINYINT(1) after deregistration")
+	}
+	if len(typeDecoderRegister) != 1 {
+		t.Errorf("expected 1 registered decoder, got %d", len(typeDecoderRegister))
+	}
+}
+
+func TestApplyDecoders(t *testing.T) {
+	rows := mysqlRows{columns: []mysqlField{
+		{fieldType: fieldTypeTiny, decoder: DecodeBool},
+		{fieldType: fieldTypeTiny, decoder: DecodeBool},
+		{fieldType: fieldTypeLong},
+	}}
+	dest := []driver.Value{[]byte("1"), nil, int64(5)}
+	if err := rows.applyDecoders(dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest[0] != true || dest[1] != nil || dest[2] != int64(5) {
+		t.Errorf("unexpected values %#v", dest)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		dest[0] = int64(0)
+		rows.applyDecoders(dest)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %f", allocs)
+	}
+}
+
+func TestDecodeBool(t *testing.T) {
+	var boolTests = []struct {
+		in  driver.Value
+		out bool
+	}{
+		{[]byte("0"), false},
+		{[]byte("1"), true},
+		{[]byte("-1"), true},
+		{int64(0), false},
+		{int64(-1), true},
+		{uint64(2), true},
+	}
+	for _, tst := range boolTests {
+		if out, err := DecodeBool(tst.in); err != nil || out != tst.out {
+			t.Errorf("%#v: expected %t, got %v (%v)", tst.in, tst.out, out, err)
+		}
+	}
+	if _, err := DecodeBool("1"); err == nil {
+		t.Error("expected error for string")
+	}
+}
+
+func TestDecodeUUID(t *testing.T) {
+	// UUID_TO_BIN('6ccd780c-baba-1026-9564-5b8c656024db')
+	plain := []byte{0x6c, 0xcd, 0x78, 0x0c, 0xba, 0xba, 0x10, 0x26, 0x95, 0x64, 0x5b, 0x8c, 0x65, 0x60, 0x24, 0xdb}
+	// UUID_TO_BIN('6ccd780c-baba-1026-9564-5b8c656024db', 1)
+	swapped := []byte{0x10, 0x26, 0xba, 0xba, 0x6c, 0xcd, 0x78, 0x0c, 0x95, 0x64, 0x5b, 0x8c, 0x65, 0x60, 0x24, 0xdb}
+	const expected = "6ccd780c-baba-1026-9564-5b8c656024db"
+
+	if out, err := DecodeUUID(false)(plain); err != nil || out != expected {
+		t.Errorf("expected %s, got %v (%v)", expected, out, err)
+	}
+	if out, err := DecodeUUID(true)(swapped); err != nil || out != expected {
+		t.Errorf("expected %s, got %v (%v)", expected, out, err)
+	}
+	if _, err := DecodeUUID(false)(plain[:15]); err == nil {
+		t.Error("expected error for 15 bytes")
+	}
+}
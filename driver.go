@@ -17,23 +17,141 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"net"
+	"sync"
 )
 
 // This struct is exported to make the driver directly accessible.
 // In general the driver is used via the database/sql package.
 type MySQLDriver struct{}
 
-type dialFunc func(*config) (net.Conn, error)
+// DialContextFunc is a function which can be used to establish the network
+// connection to addr. Custom dial functions must be registered with
+// RegisterDialContext or set as the Dialer of a Connector.
+type DialContextFunc func(ctx context.Context, addr string) (net.Conn, error)
 
-var dials map[string]dialFunc
+var (
+	dialsLock sync.RWMutex
+	dials     map[string]DialContextFunc
+)
+
+// RegisterDialContext registers a custom dial function. It can then be used
+// by the network address mynet(addr), where mynet is the registered network.
+// The context of the connection attempt and its address are passed to the
+// dial function.
+//
+//  mysql.RegisterDialContext("sidecar", func(ctx context.Context, addr string) (net.Conn, error) {
+//  	return sidecar.DialContext(ctx, addr)
+//  })
+//  db, err := sql.Open("mysql", "user:password@sidecar(db.internal:3306)/dbname")
+func RegisterDialContext(net string, dial DialContextFunc) {
+	dialsLock.Lock()
+	defer dialsLock.Unlock()
+	if dials == nil {
+		dials = make(map[string]DialContextFunc)
+	}
+	dials[net] = dial
+}
+
+// DeregisterDial removes the custom dial function registered with the given
+// network.
+func DeregisterDial(net string) {
+	dialsLock.Lock()
+	defer dialsLock.Unlock()
+	if dials != nil {
+		delete(dials, net)
+	}
+}
 
 // Open new Connection.
 // See https://github.com/go-sql-driver/mysql#dsn-data-source-name for how
 // the DSN string is formated
 func (d *MySQLDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d *MySQLDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	return NewConnector(dsn)
+}
+
+// Connector implements driver.Connector. Unlike sql.Open, sql.OpenDB with a
+// Connector parses the DSN only once and allows setting a custom Dialer:
+//
+//  connector, err := mysql.NewConnector("user:password@tcp(db.internal:3306)/dbname")
+//  if err != nil {
+//  	...
+//  }
+//  connector.Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+//  	return sidecar.DialContext(ctx, addr)
+//  }
+//  db := sql.OpenDB(connector)
+type Connector struct {
+	// Dialer establishes the network connections. If nil, the dial function
+	// registered for the network or a net.Dialer is used.
+	Dialer DialContextFunc
+
+	cfg   *config
+	hosts *hostList // nil unless the DSN has multiple hosts or they are discovered
+}
+
+// NewConnector returns a Connector for the given DSN.
+func NewConnector(dsn string) (*Connector, error) {
+	cfg, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &Connector{cfg: cfg}
+	if len(cfg.addrs) > 1 {
+		c.hosts = newHostList(cfg)
+	}
+	return c, nil
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	return &MySQLDriver{}
+}
+
+// Connect implements driver.Connector. The deadline of ctx applies to the
+// dial and the handshake.
+// If the DSN has multiple hosts, they are tried in the order of the
+// hostPolicy until a connection succeeds. Hosts which fail are skipped for
+// the hostBackoff period.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.hosts == nil {
+		return c.connect(ctx, c.cfg.addr)
+	}
+
+	err := errNoHosts
+	for _, addr := range c.hosts.order() {
+		var mc *mysqlConn
+		if mc, err = c.connect(ctx, addr); err == nil {
+			c.hosts.markUp(addr)
+			mc.hosts = c.hosts
+			return mc, nil
+		}
+		if !isHostDown(err) {
+			// e.g. access denied, which the other hosts would report as well
+			return nil, err
+		}
+		c.hosts.markDown(addr)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// connect establishes a connection to the host addr.
+func (c *Connector) connect(ctx context.Context, addr string) (*mysqlConn, error) {
 	var err error
 
 	// New mysqlConn
@@ -41,21 +159,36 @@ func (d *MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		maxPacketAllowed: maxPacketSize,
 		maxWriteSize:     maxPacketSize - 1,
 	}
-	mc.cfg, err = parseDSN(dsn)
-	if err != nil {
-		return nil, err
-	}
+
+	// Each connection may change its config
+	cfg := *c.cfg
+	cfg.addr = addr
+	mc.cfg = &cfg
 
 	// Connect to Server
-	if dial, ok := dials[mc.cfg.net]; ok {
-		mc.netConn, err = dial(mc.cfg)
+	dial := c.Dialer
+	if dial == nil {
+		dialsLock.RLock()
+		dial = dials[mc.cfg.net]
+		dialsLock.RUnlock()
+	}
+	if dial != nil {
+		// The timeout only applies to the dial, like with net.Dialer
+		dialCtx := ctx
+		if mc.cfg.timeout > 0 {
+			var cancel context.CancelFunc
+			dialCtx, cancel = context.WithTimeout(ctx, mc.cfg.timeout)
+			defer cancel()
+		}
+		mc.netConn, err = dial(dialCtx, mc.cfg.addr)
 	} else {
 		nd := net.Dialer{Timeout: mc.cfg.timeout}
-		mc.netConn, err = nd.Dial(mc.cfg.net, mc.cfg.addr)
+		mc.netConn, err = nd.DialContext(ctx, mc.cfg.net, mc.cfg.addr)
 	}
 	if err != nil {
 		return nil, err
 	}
+	mc.rawConn = mc.netConn
 
 	// Enable TCP Keepalives on TCP connections
 	if tc, ok := mc.netConn.(*net.TCPConn); ok {
@@ -67,37 +200,34 @@ func (d *MySQLDriver) Open(dsn string) (driver.Conn, error) {
 
 	mc.buf = newBuffer(mc.netConn)
 
+	// The handshake must finish before the deadline of ctx
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		mc.Close()
+		return nil, err
+	}
+	defer clearDeadline()
+
 	// Reading Handshake Initialization Packet
 	cipher, err := mc.readInitPacket()
 	if err != nil {
 		mc.Close()
 		return nil, err
 	}
+	// make a memory safe copy of the cipher slice, it is used again by
+	// auth switches and COM_CHANGE_USER after the read buffer was reused
+	mc.cipher = append([]byte(nil), cipher...)
 
 	// Send Client Authentication Packet
-	if err = mc.writeAuthPacket(cipher); err != nil {
+	if err = mc.writeAuthPacket(mc.cipher); err != nil {
 		mc.Close()
 		return nil, err
 	}
 
-	// Read Result Packet
-	err = mc.readResultOK()
-	if err != nil {
-		// Retry with old authentication method, if allowed
-		if mc.cfg.allowOldPasswords && err == errOldPassword {
-			if err = mc.writeOldAuthPacket(cipher); err != nil {
-				mc.Close()
-				return nil, err
-			}
-			if err = mc.readResultOK(); err != nil {
-				mc.Close()
-				return nil, err
-			}
-		} else {
-			mc.Close()
-			return nil, err
-		}
-
+	// Handle the result and possible auth switch requests
+	if err = mc.handleAuthResult(authNativePassword, mc.cipher); err != nil {
+		mc.Close()
+		return nil, err
 	}
 
 	// Get max allowed packet size
@@ -118,6 +248,23 @@ func (d *MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		return nil, err
 	}
 
+	// Synchronize time zones
+	if err = mc.handleServerTimeZone(); err != nil {
+		mc.Close()
+		return nil, err
+	}
+
+	// Primary detection
+	if mc.cfg.requireWritable {
+		if err = mc.checkWritable(); err != nil {
+			mc.Close()
+			return nil, err
+		}
+	}
+
+	// The session state set up by the DSN params is the initial state
+	mc.sessionModified = false
+
 	return mc, nil
 }
 
@@ -9,6 +9,7 @@
 package mysql
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"database/sql/driver"
@@ -23,6 +24,8 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/go-sql-driver/mysql/geometry"
 )
 
 var (
@@ -250,6 +253,53 @@ func TestInt(t *testing.T) {
 	})
 }
 
+func TestUint64(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (value BIGINT UNSIGNED)")
+
+		in := uint64(1<<64 - 1)
+		dbt.mustExec("INSERT INTO test VALUES (?)", in)
+
+		// binary protocol
+		var out interface{}
+		rows := dbt.mustQuery("SELECT value FROM test WHERE ? = ?", 1, 1)
+		if rows.Next() {
+			rows.Scan(&out)
+			if v, ok := out.(uint64); !ok || v != in {
+				dbt.Errorf("binary: expected uint64 %d, got %T %v", in, out, out)
+			}
+		} else {
+			dbt.Error("binary: no data")
+		}
+		rows.Close()
+
+		// text protocol
+		var nu NullUint64
+		rows = dbt.mustQuery("SELECT value FROM test")
+		if rows.Next() {
+			rows.Scan(&nu)
+			if !nu.Valid || nu.Uint64 != in {
+				dbt.Errorf("text: expected %d, got %v", in, nu)
+			}
+		} else {
+			dbt.Error("text: no data")
+		}
+		rows.Close()
+
+		// NullUint64 as parameter
+		dbt.mustExec("DELETE FROM test")
+		dbt.mustExec("INSERT INTO test VALUES (?), (?)", NullUint64{in, true}, NullUint64{})
+		rows = dbt.mustQuery("SELECT value FROM test WHERE value IS NULL OR value = ?", in)
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		if count != 2 {
+			dbt.Errorf("expected 2 rows, got %d", count)
+		}
+	})
+}
+
 func TestFloat(t *testing.T) {
 	runTests(t, dsn, func(dbt *DBTest) {
 		types := [2]string{"FLOAT", "DOUBLE"}
@@ -273,6 +323,101 @@ func TestFloat(t *testing.T) {
 	})
 }
 
+func TestDecimal(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (value DECIMAL(40,10))")
+
+		in, err := ParseDecimal("-123456789012345678901234567890.0123456789")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		dbt.mustExec("INSERT INTO test VALUES (?), (?)", in, NullDecimal{})
+
+		for _, args := range [][]interface{}{nil, {1}} {
+			query := "SELECT value FROM test ORDER BY value IS NULL"
+			if args != nil {
+				query = "SELECT value FROM test WHERE 1 = ? ORDER BY value IS NULL"
+			}
+			rows := dbt.mustQuery(query, args...)
+
+			var out NullDecimal
+			if rows.Next() {
+				rows.Scan(&out)
+				if !out.Valid || out.Decimal.Cmp(in) != 0 {
+					dbt.Errorf("expected %s, got %v", in, out)
+				}
+			} else {
+				dbt.Error("no data")
+			}
+			if rows.Next() {
+				rows.Scan(&out)
+				if out.Valid {
+					dbt.Errorf("expected NULL, got %s", out.Decimal)
+				}
+			} else {
+				dbt.Error("no data")
+			}
+
+			colTypes, err := rows.ColumnTypes()
+			if err != nil {
+				dbt.Fatal(err)
+			}
+			precision, scale, ok := colTypes[0].DecimalSize()
+			if !ok || precision != 40 || scale != 10 {
+				dbt.Errorf("expected DECIMAL(40,10), got (%d,%d) %t", precision, scale, ok)
+			}
+			rows.Close()
+		}
+	})
+}
+
+func TestBitSetEnum(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (b BIT(10), s SET('a','b','c'), e ENUM('x','y'))")
+		dbt.mustExec("INSERT INTO test VALUES (?, ?, ?)",
+			BitField{Uint64: 0x201, Valid: true}, SetValue{"a", "c"}, "y")
+
+		for _, args := range [][]interface{}{nil, {1}} {
+			query := "SELECT b, s, e FROM test"
+			if args != nil {
+				query += " WHERE 1 = ?"
+			}
+			rows := dbt.mustQuery(query, args...)
+
+			var bf BitField
+			var sv SetValue
+			var e string
+			if rows.Next() {
+				if err := rows.Scan(&bf, &sv, &e); err != nil {
+					dbt.Fatal(err)
+				}
+				if !bf.Valid || bf.Uint64 != 0x201 || !bf.Bits[0] || !bf.Bits[9] {
+					dbt.Errorf("BIT: unexpected value %+v", bf)
+				}
+				if !sv.Contains("a") || !sv.Contains("c") || len(sv) != 2 {
+					dbt.Errorf("SET: unexpected value %q", sv)
+				}
+				if e != "y" {
+					dbt.Errorf("ENUM: expected y, got %s", e)
+				}
+			} else {
+				dbt.Error("no data")
+			}
+
+			colTypes, err := rows.ColumnTypes()
+			if err != nil {
+				dbt.Fatal(err)
+			}
+			for i, name := range []string{"BIT", "SET", "ENUM"} {
+				if colTypes[i].DatabaseTypeName() != name {
+					dbt.Errorf("expected %s, got %s", name, colTypes[i].DatabaseTypeName())
+				}
+			}
+			rows.Close()
+		}
+	})
+}
+
 func TestString(t *testing.T) {
 	runTests(t, dsn, func(dbt *DBTest) {
 		types := [6]string{"CHAR(255)", "VARCHAR(255)", "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT"}
@@ -414,6 +559,156 @@ func TestDateTime(t *testing.T) {
 	}
 }
 
+func TestGeometry(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (value GEOMETRY)")
+
+		in := geometry.Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 0}}}
+		dbt.mustExec("INSERT INTO test VALUES (?)", in)
+		dbt.mustExec("INSERT INTO test VALUES (ST_GeomFromText('POINT(1 2)'))")
+
+		var wkt string
+		rows := dbt.mustQuery("SELECT ST_AsText(value) FROM test")
+		if rows.Next() {
+			rows.Scan(&wkt)
+			if wkt != in.WKT() {
+				dbt.Errorf("expected %s, got %s", in.WKT(), wkt)
+			}
+		} else {
+			dbt.Error("no data")
+		}
+		rows.Close()
+
+		for _, args := range [][]interface{}{nil, {1}} {
+			query := "SELECT value FROM test"
+			if args != nil {
+				query += " WHERE 1 = ?"
+			}
+			rows = dbt.mustQuery(query, args...)
+			var out geometry.Polygon
+			var g geometry.Geom
+			if rows.Next() {
+				rows.Scan(&out)
+				if out.WKT() != in.WKT() {
+					dbt.Errorf("expected %s, got %s", in.WKT(), out.WKT())
+				}
+			} else {
+				dbt.Error("no data")
+			}
+			if rows.Next() {
+				rows.Scan(&g)
+				if g.Geometry == nil || g.Geometry.WKT() != "POINT(1 2)" {
+					dbt.Errorf("expected POINT(1 2), got %v", g.Geometry)
+				}
+			} else {
+				dbt.Error("no data")
+			}
+			rows.Close()
+		}
+	})
+}
+
+func TestTypeDecoder(t *testing.T) {
+	RegisterTypeDecoder(TypeDecoderKey{Type: FieldTypeString, Flags: FlagBinary, Length: 16}, DecodeUUID(true))
+	RegisterTypeDecoder(TypeDecoderKey{Type: FieldTypeTiny, Length: 1}, DecodeBool)
+	defer func() { typeDecoderRegister = nil }()
+
+	runTests(t, dsn, func(dbt *DBTest) {
+		const uuid = "6ccd780c-baba-1026-9564-5b8c656024db"
+		dbt.mustExec("CREATE TABLE test (id BINARY(16), active TINYINT(1), n TINYINT)")
+		dbt.mustExec("INSERT INTO test VALUES (UUID_TO_BIN(?, 1), 1, 1)", uuid)
+
+		for _, args := range [][]interface{}{nil, {1}} {
+			query := "SELECT id, active, n FROM test"
+			if args != nil {
+				query += " WHERE 1 = ?"
+			}
+			rows := dbt.mustQuery(query, args...)
+			var id, active, n interface{}
+			if rows.Next() {
+				rows.Scan(&id, &active, &n)
+				if id != uuid {
+					dbt.Errorf("expected %s, got %#v", uuid, id)
+				}
+				if active != true {
+					dbt.Errorf("expected true, got %#v", active)
+				}
+				if _, ok := n.(bool); ok {
+					dbt.Errorf("unexpected bool for TINYINT(4)")
+				}
+			} else {
+				dbt.Error("no data")
+			}
+			rows.Close()
+		}
+	})
+}
+
+func TestZeroDateModes(t *testing.T) {
+	tMin := time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+	var modeTests = []struct {
+		mode string
+		out  interface{}
+		err  bool
+	}{
+		{"zero", time.Time{}, false},
+		{"null", nil, false},
+		{"error", nil, true},
+		{"minDate", tMin, false},
+	}
+
+	for _, tst := range modeTests {
+		runTests(t, dsn+"&parseTime=true&sql_mode=ALLOW_INVALID_DATES&zeroDate="+tst.mode, func(dbt *DBTest) {
+			dbt.mustExec("CREATE TABLE test (value DATETIME)")
+			dbt.mustExec("INSERT INTO test VALUES ('0000-00-00 00:00:00'), ('2020-00-15 00:00:00')")
+
+			for _, args := range [][]interface{}{nil, {1}} {
+				query := "SELECT value FROM test"
+				if args != nil {
+					query += " WHERE 1 = ?"
+				}
+				rows := dbt.mustQuery(query, args...)
+				for rows.Next() {
+					var out interface{}
+					err := rows.Scan(&out)
+					if (err != nil) != tst.err {
+						dbt.Errorf("%s: expected error status %t, got %v", tst.mode, tst.err, err)
+					} else if err == nil && out != tst.out {
+						dbt.Errorf("%s: expected %v, got %v", tst.mode, tst.out, out)
+					}
+				}
+				if err := rows.Err(); err != nil && !tst.err {
+					dbt.Errorf("%s: %s", tst.mode, err.Error())
+				}
+				rows.Close()
+			}
+
+			// zero time.Time as parameter
+			dbt.mustExec("DELETE FROM test")
+			_, err := dbt.db.Exec("INSERT INTO test VALUES (?)", time.Time{})
+			if tst.err {
+				if err == nil {
+					dbt.Errorf("%s: expected error for zero time.Time", tst.mode)
+				}
+				return
+			} else if err != nil {
+				dbt.Fatalf("%s: %s", tst.mode, err.Error())
+			}
+
+			var out NullTime
+			if err = dbt.db.QueryRow("SELECT value FROM test WHERE value IS NULL OR value <= ?", tMin).Scan(&out); err != nil {
+				dbt.Fatalf("%s: %s", tst.mode, err.Error())
+			}
+			if tst.mode == "null" && out.Valid {
+				dbt.Errorf("%s: expected NULL, got %v", tst.mode, out.Time)
+			}
+			if tst.mode == "minDate" && out.Time != tMin {
+				dbt.Errorf("%s: expected %v, got %v", tst.mode, tMin, out.Time)
+			}
+		})
+	}
+}
+
 func TestNULL(t *testing.T) {
 	runTests(t, dsn, func(dbt *DBTest) {
 		nullStmt, err := dbt.db.Prepare("SELECT NULL")
@@ -712,6 +1007,384 @@ func TestLoadData(t *testing.T) {
 	})
 }
 
+func TestResult(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (id INT NOT NULL AUTO_INCREMENT PRIMARY KEY, data INT)")
+
+		conn, err := dbt.db.Conn(context.Background())
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		exec := func(query string) (res Result) {
+			err := conn.Raw(func(driverConn interface{}) error {
+				dres, err := driverConn.(driver.Execer).Exec(query, nil)
+				if err == nil {
+					res = dres.(Result)
+				}
+				return err
+			})
+			if err != nil {
+				dbt.Fatalf("%s: %s", query, err.Error())
+			}
+			return res
+		}
+
+		res := exec("INSERT INTO test (data) VALUES (0), (0), (1)")
+		if first, last, ok := res.InsertIDRange(); !ok || first != 1 || last != 3 {
+			dbt.Errorf("expected insert IDs [1, 3], got [%d, %d] (%v)", first, last, ok)
+		}
+		if res.LastInsertID() != 1 {
+			dbt.Errorf("expected last insert ID 1, got %d", res.LastInsertID())
+		}
+		if res.Status()&StatusInAutocommit == 0 {
+			dbt.Errorf("expected autocommit status, got %x", res.Status())
+		}
+
+		// matched vs changed
+		res = exec("UPDATE test SET data = 1")
+		matched, ok1 := res.MatchedRows()
+		changed, ok2 := res.ChangedRows()
+		if !ok1 || !ok2 || matched != 3 || changed != 2 {
+			dbt.Errorf("expected 3 matched and 2 changed rows, got %d and %d (info %q)", matched, changed, res.Info())
+		}
+
+		res = exec("UPDATE test SET data = 1 WHERE id = 42")
+		if matched, ok := res.MatchedRows(); !ok || matched != 0 {
+			dbt.Errorf("expected no matched rows, got %d (info %q)", matched, res.Info())
+		}
+	})
+}
+
+func TestSessionState(t *testing.T) {
+	runTests(t, dsn+"&session_track_state_change=ON", func(dbt *DBTest) {
+		conn, err := dbt.db.Conn(context.Background())
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		var handled int
+		SetSessionStateHandler(func(state *SessionState) {
+			handled++
+		})
+		defer SetSessionStateHandler(nil)
+
+		if _, err = conn.ExecContext(context.Background(), "SET time_zone = '+01:00'"); err != nil {
+			dbt.Fatal(err)
+		}
+
+		err = conn.Raw(func(driverConn interface{}) error {
+			mc := driverConn.(*mysqlConn)
+			if mc.flags&clientSessionTrack == 0 {
+				dbt.Skip("server does not support session tracking")
+			}
+
+			state := mc.SessionState()
+			if state == nil {
+				dbt.Fatal("expected a session state")
+			}
+			if !state.StateChanged || state.SystemVariables["time_zone"] != "+01:00" {
+				dbt.Errorf("unexpected session state %+v", state)
+			}
+			if !mc.sessionModified {
+				dbt.Error("expected the session to be modified")
+			}
+			return nil
+		})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if handled != 1 {
+			dbt.Errorf("expected the handler to be called once, got %d", handled)
+		}
+	})
+}
+
+func TestQueryAttributes(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		ctx := WithQueryAttributes(context.Background(), map[string]interface{}{
+			"trace_id": "4bf92f3577b34da6",
+			"tenant":   42,
+		})
+
+		// requires MySQL 8.0.23+ with the query_attributes component
+		var traceID, tenant sql.NullString
+		err := dbt.db.QueryRowContext(ctx, "SELECT mysql_query_attribute_string('trace_id'), mysql_query_attribute_string('tenant')").Scan(&traceID, &tenant)
+		if err != nil {
+			dbt.Skipf("query attributes not supported: %s", err.Error())
+		}
+		if traceID.String != "4bf92f3577b34da6" || tenant.String != "42" {
+			dbt.Errorf("unexpected attributes %v, %v", traceID, tenant)
+		}
+
+		// prepared statement
+		err = dbt.db.QueryRowContext(ctx, "SELECT mysql_query_attribute_string('tenant'), ?", 1).Scan(&tenant, new(int))
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if tenant.String != "42" {
+			dbt.Errorf("unexpected attribute %v", tenant)
+		}
+
+		// only sent with the context
+		err = dbt.db.QueryRow("SELECT mysql_query_attribute_string('tenant')").Scan(&tenant)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if tenant.Valid {
+			dbt.Errorf("expected no attribute, got %v", tenant)
+		}
+	})
+}
+
+func TestPingAndLiveness(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		if err := dbt.db.Ping(); err != nil {
+			dbt.Fatalf("Ping failed: %s", err.Error())
+		}
+
+		dbt.db.SetMaxIdleConns(1)
+		dbt.db.SetMaxOpenConns(1)
+
+		var id int64
+		if err := dbt.db.QueryRow("SELECT CONNECTION_ID()").Scan(&id); err != nil {
+			dbt.Fatal(err)
+		}
+
+		// Kill the idle connection from another connection
+		killer, err := sql.Open("mysql", dsn)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer killer.Close()
+		if _, err = killer.Exec(fmt.Sprintf("KILL %d", id)); err != nil {
+			dbt.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		// The dead connection must be dropped before it is reused
+		var newID int64
+		if err = dbt.db.QueryRow("SELECT CONNECTION_ID()").Scan(&newID); err != nil {
+			dbt.Fatalf("expected a new connection, got %s", err.Error())
+		}
+		if newID == id {
+			dbt.Errorf("expected a new connection, got the killed one")
+		}
+	})
+}
+
+func TestResetSession(t *testing.T) {
+	runTests(t, dsn+"&resetSession=true&sql_mode=ANSI_QUOTES", func(dbt *DBTest) {
+		dbt.db.SetMaxIdleConns(1)
+		dbt.db.SetMaxOpenConns(1)
+
+		stmt, err := dbt.db.Prepare("SELECT ?")
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer stmt.Close()
+
+		dbt.mustExec("SET @leak = 1")
+		dbt.mustExec("CREATE TEMPORARY TABLE leak (id INT)")
+
+		var leak sql.NullInt64
+		if err = dbt.db.QueryRow("SELECT @leak").Scan(&leak); err != nil {
+			dbt.Fatal(err)
+		}
+		if leak.Valid {
+			dbt.Errorf("expected the user variable to be reset, got %v", leak)
+		}
+		if _, err = dbt.db.Exec("SELECT * FROM leak"); err == nil {
+			dbt.Error("expected the temporary table to be dropped")
+		}
+
+		// DSN params are applied again
+		var sqlMode string
+		if err = dbt.db.QueryRow("SELECT @@sql_mode").Scan(&sqlMode); err != nil {
+			dbt.Fatal(err)
+		}
+		if sqlMode != "ANSI_QUOTES" {
+			dbt.Errorf("expected sql_mode ANSI_QUOTES, got %s", sqlMode)
+		}
+
+		// the statement is prepared again
+		var out int
+		if err = stmt.QueryRow(42).Scan(&out); err != nil {
+			dbt.Fatal(err)
+		}
+		if out != 42 {
+			dbt.Errorf("expected 42, got %d", out)
+		}
+	})
+}
+
+func TestResetSessionDatabase(t *testing.T) {
+	connector, err := NewConnector("user@tcp(server:3306)/dbname?resetSession=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// like the server, the fake keeps the default database on
+	// COM_RESET_CONNECTION
+	var mu sync.Mutex
+	var database string
+	var commands []string
+	connector.Dialer = fakeDialer(func(query string) [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, query)
+
+		switch {
+		case strings.HasPrefix(query, "USE "):
+			database = query[len("USE "):]
+		case strings.HasPrefix(query, "INIT_DB "):
+			database = query[len("INIT_DB "):]
+		case query == "SELECT DATABASE()":
+			return fakeResultSet([]string{"DATABASE()"}, []string{database})
+		}
+		return nil
+	})
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec("USE other"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reused connection uses the database of the DSN again
+	var name string
+	if err = db.QueryRow("SELECT DATABASE()").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "dbname" {
+		t.Errorf("expected database dbname, got %s", name)
+	}
+	mu.Lock()
+	got := strings.Join(commands, "; ")
+	mu.Unlock()
+	if want := "USE other; RESET_CONNECTION; INIT_DB dbname; SELECT DATABASE()"; got != want {
+		t.Errorf("expected commands %q, got %q", want, got)
+	}
+}
+
+func TestChangeUser(t *testing.T) {
+	runTests(t, dsn, func(dbt *DBTest) {
+		cfg, err := parseDSN(dsn)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		changeUser := func(user, passwd string) error {
+			return conn.Raw(func(driverConn interface{}) error {
+				return driverConn.(*mysqlConn).ChangeUser(ctx, user, passwd, cfg.dbname)
+			})
+		}
+
+		if _, err = conn.ExecContext(ctx, "SET @leak = 1"); err != nil {
+			dbt.Fatal(err)
+		}
+		if err = changeUser(cfg.user, cfg.passwd); err != nil {
+			dbt.Fatal(err)
+		}
+
+		var user string
+		var leak sql.NullInt64
+		if err = conn.QueryRowContext(ctx, "SELECT SUBSTRING_INDEX(USER(), '@', 1), @leak").Scan(&user, &leak); err != nil {
+			dbt.Fatal(err)
+		}
+		if user != cfg.user {
+			dbt.Errorf("expected user %s, got %s", cfg.user, user)
+		}
+		if leak.Valid {
+			dbt.Errorf("expected the session to be reset, got %v", leak)
+		}
+
+		// the connection is closed after a failed change
+		if err = changeUser(cfg.user, cfg.passwd+"wrong"); err == nil {
+			dbt.Fatal("expected error for wrong password")
+		}
+		if err = conn.PingContext(ctx); err != driver.ErrBadConn {
+			dbt.Errorf("expected driver.ErrBadConn, got %v", err)
+		}
+	})
+}
+
+func TestConnInterface(t *testing.T) {
+	runTests(t, dsn+"&charset=utf8mb4,utf8", func(dbt *DBTest) {
+		ctx := context.Background()
+		conn, err := dbt.db.Conn(ctx)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		var id uint32
+		var version, dbname string
+		if err = conn.QueryRowContext(ctx, "SELECT CONNECTION_ID(), VERSION(), DATABASE()").Scan(&id, &version, &dbname); err != nil {
+			dbt.Fatal(err)
+		}
+		if _, err = conn.ExecContext(ctx, "BEGIN"); err != nil {
+			dbt.Fatal(err)
+		}
+
+		err = conn.Raw(func(driverConn interface{}) error {
+			mc := driverConn.(Conn)
+			if mc.ConnectionID() != id {
+				dbt.Errorf("expected connection id %d, got %d", id, mc.ConnectionID())
+			}
+			if mc.ServerVersion() != version {
+				dbt.Errorf("expected server version %s, got %s", version, mc.ServerVersion())
+			}
+			if info := mc.ServerInfo(); info.ConnectionID != id || !info.VersionAtLeast(5, 0, 0) {
+				dbt.Errorf("unexpected server info %+v", info)
+			}
+			if mc.Capabilities()&uint32(clientProtocol41) == 0 {
+				dbt.Errorf("unexpected capabilities %x", mc.Capabilities())
+			}
+			if charset := mc.Charset(); charset != "utf8mb4" && charset != "utf8" {
+				dbt.Errorf("unexpected charset %s", charset)
+			}
+			if !mc.InTransaction() {
+				dbt.Error("expected an active transaction")
+			}
+
+			stats, err := mc.Statistics(ctx)
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(stats, "Uptime: ") {
+				dbt.Errorf("unexpected statistics %q", stats)
+			}
+			return mc.InitDB(ctx, "information_schema")
+		})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+
+		var current string
+		if err = conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&current); err != nil {
+			dbt.Fatal(err)
+		}
+		if current != "information_schema" {
+			dbt.Errorf("expected database information_schema, got %s", current)
+		}
+
+		// the connection is returned to the pool
+		conn.ExecContext(ctx, "ROLLBACK")
+		conn.ExecContext(ctx, "USE "+dbname)
+	})
+}
+
 func TestFoundRows(t *testing.T) {
 	runTests(t, dsn, func(dbt *DBTest) {
 		dbt.mustExec("CREATE TABLE test (id INT NOT NULL ,data INT NOT NULL)")
@@ -757,6 +1430,56 @@ func TestFoundRows(t *testing.T) {
 	})
 }
 
+func TestWarnings(t *testing.T) {
+	// ALLOW_INVALID_DATES to get rid of stricter modes - we want to test for warnings, not errors
+	relaxedDsn := strings.Replace(dsn, "&strict=true", "", 1) + "&sql_mode=ALLOW_INVALID_DATES"
+
+	const truncate = "INSERT INTO test VALUES(10,'mysql')"
+
+	// ignore: the statements succeed and the warnings can be retrieved on demand
+	runTests(t, relaxedDsn+"&warnings=ignore", func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (a TINYINT NOT NULL, b CHAR(4))")
+
+		conn, err := dbt.db.Conn(context.Background())
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		defer conn.Close()
+
+		if _, err = conn.ExecContext(context.Background(), truncate); err != nil {
+			dbt.Fatalf("expected the insert to succeed, got %v", err)
+		}
+
+		err = conn.Raw(func(driverConn interface{}) error {
+			if count := driverConn.(interface{ WarningCount() uint16 }).WarningCount(); count != 1 {
+				dbt.Errorf("expected 1 warning, got %d", count)
+			}
+			warnings, err := driverConn.(interface{ Warnings() (MySQLWarnings, error) }).Warnings()
+			if err != nil {
+				return err
+			}
+			if len(warnings) != 1 || warnings[0].Code != "1265" || !strings.Contains(warnings[0].Message, "'b'") {
+				dbt.Errorf("unexpected warnings %v", warnings)
+			}
+			return nil
+		})
+		if err != nil {
+			dbt.Fatal(err)
+		}
+	})
+
+	// error: notes are filtered, warnings are returned as errors
+	runTests(t, relaxedDsn+"&warnings=error", func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (a TINYINT NOT NULL, b CHAR(4))")
+		dbt.mustExec("DROP TABLE IF EXISTS no_such_table")
+
+		_, err := dbt.db.Exec(truncate)
+		if warnings, ok := err.(MySQLWarnings); !ok || len(warnings) != 1 || warnings[0].Code != "1265" {
+			dbt.Errorf("expected truncation warning, got %v", err)
+		}
+	})
+}
+
 func TestStrict(t *testing.T) {
 	// ALLOW_INVALID_DATES to get rid of stricter modes - we want to test for warnings, not errors
 	relaxedDsn := dsn + "&sql_mode=ALLOW_INVALID_DATES"
@@ -995,6 +1718,41 @@ func TestTimezoneConversion(t *testing.T) {
 	}
 }
 
+func TestServerTimeZone(t *testing.T) {
+	zones := []string{"UTC", "US/Central", "Asia/Kolkata", "Local"}
+
+	roundTrip := func(dbt *DBTest) {
+		dbt.mustExec("CREATE TABLE test (ts TIMESTAMP NULL, dt DATETIME)")
+
+		// Both columns must return the same instant in time
+		now := time.Now().Truncate(time.Second)
+		dbt.mustExec("INSERT INTO test VALUES (?, ?)", now, now)
+
+		var ts, dt time.Time
+		var diff int64
+		err := dbt.db.QueryRow("SELECT ts, dt, TIMESTAMPDIFF(SECOND, ts, NOW()) FROM test").Scan(&ts, &dt, &diff)
+		if err != nil {
+			dbt.Fatal(err)
+		}
+		if !ts.Equal(now) {
+			dbt.Errorf("TIMESTAMP: expected %v, got %v", now, ts)
+		}
+		if !dt.Equal(now) {
+			dbt.Errorf("DATETIME: expected %v, got %v", now, dt)
+		}
+
+		// NOW() must agree with the session time zone
+		if diff < -60 || diff > 60 {
+			dbt.Errorf("NOW() is off by %d seconds", diff)
+		}
+	}
+
+	for _, tz := range zones {
+		runTests(t, dsn+"&parseTime=true&serverTimeZone=sync&loc="+url.QueryEscape(tz), roundTrip)
+	}
+	runTests(t, dsn+"&parseTime=true&serverTimeZone=read", roundTrip)
+}
+
 // This tests for https://github.com/go-sql-driver/mysql/pull/139
 //
 // An extra (invisible) nil byte was being added to the beginning of positive
@@ -1302,3 +2060,105 @@ func TestConcurrent(t *testing.T) {
 		dbt.Logf("Reached %d concurrent connections\r\n", succeeded)
 	})
 }
+
+func TestRegisterDialContext(t *testing.T) {
+	var dialedAddr string
+	fake := fakeDialer(nil)
+	RegisterDialContext("fakenet", func(ctx context.Context, addr string) (net.Conn, error) {
+		dialedAddr = addr
+		return fake(ctx, addr)
+	})
+
+	db, err := sql.Open("mysql", "user@fakenet(db.internal:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if dialedAddr != "db.internal:3306" {
+		t.Errorf("expected address db.internal:3306, got %s", dialedAddr)
+	}
+
+	DeregisterDial("fakenet")
+	if _, err = (&MySQLDriver{}).Open("user@fakenet(db.internal:3306)/dbname"); err == nil {
+		t.Error("expected error for deregistered network")
+	}
+}
+
+func TestConnectorDialer(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db.internal:3306)/dbname?timeout=10s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deadline bool
+	fake := fakeDialer(func(query string) [][]byte {
+		return fakeResultSet([]string{"1"}, []string{"1"})
+	})
+	connector.Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		_, deadline = ctx.Deadline()
+		return fake(ctx, addr)
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	var one int
+	if err = db.QueryRow("SELECT 1").Scan(&one); err != nil {
+		t.Fatal(err)
+	}
+	if one != 1 {
+		t.Errorf("expected 1, got %d", one)
+	}
+	if !deadline {
+		t.Error("expected the timeout as deadline of the dial context")
+	}
+}
+
+func TestConnectorHandshakeDeadline(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db.internal:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server never sends the greeting
+	connector.Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		t.Cleanup(func() { server.Close() })
+		return client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = connector.Connect(ctx); err == nil {
+		t.Fatal("expected timeout error")
+	}
+}
+
+func TestConnectorDialTimeout(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db.internal:3306)/dbname?timeout=50ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the greeting is sent after the dial timeout
+	connector.Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected a deadline for the dial")
+		}
+		client, server := net.Pipe()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			serveFake(server, nil, nil)
+		}()
+		return client, nil
+	}
+
+	conn, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,136 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+)
+
+// MySQL server error numbers, named like in the MySQL source.
+// See http://dev.mysql.com/doc/refman/5.6/en/error-messages-server.html
+const (
+	ER_DUP_KEY                               uint16 = 1022
+	ER_CON_COUNT_ERROR                       uint16 = 1040
+	ER_DBACCESS_DENIED_ERROR                 uint16 = 1044
+	ER_ACCESS_DENIED_ERROR                   uint16 = 1045
+	ER_UNKNOWN_COM_ERROR                     uint16 = 1047
+	ER_BAD_DB_ERROR                          uint16 = 1049
+	ER_SERVER_SHUTDOWN                       uint16 = 1053
+	ER_DUP_ENTRY                             uint16 = 1062
+	ER_PARSE_ERROR                           uint16 = 1064
+	ER_NORMAL_SHUTDOWN                       uint16 = 1077
+	ER_NO_SUCH_TABLE                         uint16 = 1146
+	ER_NET_READ_ERROR                        uint16 = 1158
+	ER_NET_READ_INTERRUPTED                  uint16 = 1159
+	ER_NET_ERROR_ON_WRITE                    uint16 = 1160
+	ER_NET_WRITE_INTERRUPTED                 uint16 = 1161
+	ER_LOCK_WAIT_TIMEOUT                     uint16 = 1205
+	ER_LOCK_DEADLOCK                         uint16 = 1213
+	ER_NO_REFERENCED_ROW                     uint16 = 1216
+	ER_ROW_IS_REFERENCED                     uint16 = 1217
+	ER_OPTION_PREVENTS_STATEMENT             uint16 = 1290
+	ER_UNKNOWN_TIME_ZONE                     uint16 = 1298
+	ER_QUERY_INTERRUPTED                     uint16 = 1317
+	ER_ROW_IS_REFERENCED_2                   uint16 = 1451
+	ER_NO_REFERENCED_ROW_2                   uint16 = 1452
+	ER_DUP_ENTRY_WITH_KEY_NAME               uint16 = 1586
+	ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION uint16 = 1792
+	ER_READ_ONLY_MODE                        uint16 = 1836
+	ER_CONNECTION_KILLED                     uint16 = 1927
+)
+
+// MySQL client error numbers. Some proxies forward these to the client.
+const (
+	CR_CONN_HOST_ERROR   uint16 = 2003
+	CR_SERVER_GONE_ERROR uint16 = 2006
+	CR_SERVER_LOST       uint16 = 2013
+)
+
+// SQLSTATE class of connection exceptions
+const sqlStateClassConnection = "08"
+
+// asMySQLError returns the *MySQLError in the chain of err, if any
+func asMySQLError(err error) (*MySQLError, bool) {
+	var me *MySQLError
+	if errors.As(err, &me) && me != nil {
+		return me, true
+	}
+	return nil, false
+}
+
+// hasErrorNumber reports whether err is a MySQL error with one of the given
+// error numbers
+func hasErrorNumber(err error, numbers ...uint16) bool {
+	if me, ok := asMySQLError(err); ok {
+		for _, number := range numbers {
+			if me.Number == number {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsDuplicateKey reports whether err is caused by a duplicate value in a
+// unique or primary key.
+func IsDuplicateKey(err error) bool {
+	return hasErrorNumber(err, ER_DUP_ENTRY, ER_DUP_KEY, ER_DUP_ENTRY_WITH_KEY_NAME)
+}
+
+// IsDeadlock reports whether err is caused by a deadlock. The transaction
+// was rolled back and may be retried.
+func IsDeadlock(err error) bool {
+	return hasErrorNumber(err, ER_LOCK_DEADLOCK)
+}
+
+// IsLockTimeout reports whether err is caused by a lock wait timeout. Only
+// the statement was rolled back, unless innodb_rollback_on_timeout is set.
+func IsLockTimeout(err error) bool {
+	return hasErrorNumber(err, ER_LOCK_WAIT_TIMEOUT)
+}
+
+// IsReadOnly reports whether err is caused by writing to a read-only server,
+// e.g. a replica running with --read-only, or in a read-only transaction.
+// Error 1290 for other options, e.g. --secure-file-priv, doesn't match.
+func IsReadOnly(err error) bool {
+	if me, ok := asMySQLError(err); ok {
+		return isReadOnlyMode(me) || me.Number == ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+	}
+	return false
+}
+
+// IsConnectionLost reports whether err is caused by a broken connection to
+// the server, either detected by the driver or reported by the server.
+// The outcome of the statement which caused the error is unknown.
+func IsConnectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, errInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr *net.OpError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if me, ok := asMySQLError(err); ok {
+		if string(me.SQLState[:2]) == sqlStateClassConnection {
+			return true
+		}
+		return hasErrorNumber(me, ER_SERVER_SHUTDOWN, ER_NORMAL_SHUTDOWN,
+			ER_CONNECTION_KILLED, ER_NET_READ_ERROR, ER_NET_READ_INTERRUPTED,
+			ER_NET_ERROR_ON_WRITE, ER_NET_WRITE_INTERRUPTED,
+			CR_CONN_HOST_ERROR, CR_SERVER_GONE_ERROR, CR_SERVER_LOST)
+	}
+	return false
+}
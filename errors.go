@@ -9,10 +9,8 @@
 package mysql
 
 import (
-	"database/sql/driver"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 )
@@ -27,6 +25,17 @@ var (
 	errPktSyncMul  = errors.New("Commands out of sync. Did you run multiple statements at once?")
 	errPktTooLarge = errors.New("Packet for query is too large. You can change this value on the server by adjusting the 'max_allowed_packet' variable.")
 	errBusyBuffer  = errors.New("Busy buffer")
+	errZeroDate    = errors.New("Zero date can't be converted to time.Time. Use the DSN parameter 'zeroDate' to change how zero dates are handled")
+	errZeroTime    = errors.New("Zero time.Time can't be sent with zeroDate=error")
+	errUnreadData  = errors.New("Unexpected data on idle connection")
+	errPublicKey   = errors.New("Invalid public key received from the server")
+	errReadOnly    = errors.New("Server is read-only but requireWritable is set")
+	errNoReplica   = errors.New("Server is not a replica, SHOW REPLICA STATUS is empty")
+	errReplStopped = errors.New("Replication is not running, Seconds_Behind_Source is NULL")
+	errGTIDSet     = errors.New("Invalid GTID set")
+	errTxOptions   = errors.New("Isolation levels and read-only transactions are not supported")
+	errNoHosts     = errors.New("No hosts available")
+	errNoCluster   = errors.New("Server is not an online member of a Group Replication or Galera cluster")
 
 	errLog Logger = log.New(os.Stderr, "[MySQL] ", log.Ldate|log.Ltime|log.Lshortfile)
 )
@@ -46,16 +55,31 @@ func SetLogger(logger Logger) error {
 	return nil
 }
 
-// MySQLError is an error type which represents a single MySQL error
+// MySQLError is an error type which represents a single MySQL error.
+// SQLState is zero if the server didn't send a SQLSTATE.
 type MySQLError struct {
-	Number  uint16
-	Message string
+	Number   uint16
+	SQLState [5]byte
+	Message  string
 }
 
 func (me *MySQLError) Error() string {
 	return fmt.Sprintf("Error %d: %s", me.Number, me.Message)
 }
 
+// Is reports whether target is a *MySQLError with the same error number,
+// so that errors.Is can be used to test for a specific MySQL error:
+//
+//  if errors.Is(err, &mysql.MySQLError{Number: mysql.ER_DUP_ENTRY}) {
+//  ...
+//
+func (me *MySQLError) Is(target error) bool {
+	if t, ok := target.(*MySQLError); ok && t != nil {
+		return me.Number == t.Number
+	}
+	return false
+}
+
 // MySQLWarnings is an error type which represents a group of one or more MySQL
 // warnings
 type MySQLWarnings []MysqlWarning
@@ -83,50 +107,3 @@ type MysqlWarning struct {
 	Code    string
 	Message string
 }
-
-func (mc *mysqlConn) getWarnings() (err error) {
-	rows, err := mc.Query("SHOW WARNINGS", []driver.Value{})
-	if err != nil {
-		return
-	}
-
-	var warnings = MySQLWarnings{}
-	var values = make([]driver.Value, 3)
-
-	var warning MysqlWarning
-	var raw []byte
-	var ok bool
-
-	for {
-		err = rows.Next(values)
-		switch err {
-		case nil:
-			warning = MysqlWarning{}
-
-			if raw, ok = values[0].([]byte); ok {
-				warning.Level = string(raw)
-			} else {
-				warning.Level = fmt.Sprintf("%s", values[0])
-			}
-			if raw, ok = values[1].([]byte); ok {
-				warning.Code = string(raw)
-			} else {
-				warning.Code = fmt.Sprintf("%s", values[1])
-			}
-			if raw, ok = values[2].([]byte); ok {
-				warning.Message = string(raw)
-			} else {
-				warning.Message = fmt.Sprintf("%s", values[0])
-			}
-
-			warnings = append(warnings, warning)
-
-		case io.EOF:
-			return warnings
-
-		default:
-			rows.Close()
-			return
-		}
-	}
-}
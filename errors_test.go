@@ -10,7 +10,14 @@ package mysql
 
 import (
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,3 +35,170 @@ func TestSetLogger(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, actual)
 	}
 }
+
+func TestHandleErrorPacket(t *testing.T) {
+	mc := &mysqlConn{}
+
+	err := mc.handleErrorPacket([]byte("\xff\x26\x04#23000Duplicate entry '1' for key 'PRIMARY'"))
+	me, ok := err.(*MySQLError)
+	if !ok {
+		t.Fatalf("expected *MySQLError, got %T", err)
+	}
+	if me.Number != ER_DUP_ENTRY {
+		t.Errorf("expected number %d, got %d", ER_DUP_ENTRY, me.Number)
+	}
+	if string(me.SQLState[:]) != "23000" {
+		t.Errorf("expected SQLSTATE 23000, got %q", me.SQLState[:])
+	}
+	if me.Message != "Duplicate entry '1' for key 'PRIMARY'" {
+		t.Errorf("unexpected message %q", me.Message)
+	}
+
+	// without SQLSTATE (pre-4.1 protocol)
+	err = mc.handleErrorPacket([]byte("\xff\x10\x04Too many connections"))
+	if me, ok = err.(*MySQLError); !ok {
+		t.Fatalf("expected *MySQLError, got %T", err)
+	}
+	if me.Number != ER_CON_COUNT_ERROR || me.SQLState != [5]byte{} || me.Message != "Too many connections" {
+		t.Errorf("unexpected error %#v", me)
+	}
+
+	if err = mc.handleErrorPacket([]byte("\xff\x10")); err != errMalformPkt {
+		t.Errorf("expected errMalformPkt, got %v", err)
+	}
+}
+
+func TestMySQLErrorIs(t *testing.T) {
+	err := fmt.Errorf("insert failed: %w", &MySQLError{Number: ER_DUP_ENTRY, Message: "Duplicate entry"})
+
+	if !errors.Is(err, &MySQLError{Number: ER_DUP_ENTRY}) {
+		t.Error("expected errors.Is to match the error number")
+	}
+	if errors.Is(err, &MySQLError{Number: ER_LOCK_DEADLOCK}) {
+		t.Error("expected errors.Is not to match a different error number")
+	}
+
+	var me *MySQLError
+	if !errors.As(err, &me) || me.Message != "Duplicate entry" {
+		t.Errorf("expected errors.As to find the MySQLError, got %v", me)
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("wrapped: %w", err)
+	}
+	connLost := &MySQLError{Number: 1234, SQLState: [5]byte{'0', '8', 'S', '0', '1'}}
+
+	var predicateTests = []struct {
+		name      string
+		predicate func(error) bool
+		match     []error
+		noMatch   []error
+	}{
+		{"IsDuplicateKey", IsDuplicateKey,
+			[]error{&MySQLError{Number: ER_DUP_ENTRY}, wrap(&MySQLError{Number: ER_DUP_ENTRY_WITH_KEY_NAME})},
+			[]error{nil, errors.New("Duplicate entry"), &MySQLError{Number: ER_LOCK_DEADLOCK}},
+		},
+		{"IsDeadlock", IsDeadlock,
+			[]error{&MySQLError{Number: ER_LOCK_DEADLOCK}, wrap(&MySQLError{Number: ER_LOCK_DEADLOCK})},
+			[]error{nil, &MySQLError{Number: ER_LOCK_WAIT_TIMEOUT}},
+		},
+		{"IsLockTimeout", IsLockTimeout,
+			[]error{&MySQLError{Number: ER_LOCK_WAIT_TIMEOUT}},
+			[]error{nil, &MySQLError{Number: ER_LOCK_DEADLOCK}},
+		},
+		{"IsReadOnly", IsReadOnly,
+			[]error{&MySQLError{Number: ER_OPTION_PREVENTS_STATEMENT, Message: "The MySQL server is running with the --read-only option so it cannot execute this statement"},
+				&MySQLError{Number: ER_OPTION_PREVENTS_STATEMENT, Message: "The MySQL server is running with the --super-read-only option so it cannot execute this statement"},
+				&MySQLError{Number: ER_READ_ONLY_MODE}, &MySQLError{Number: ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION}},
+			[]error{nil, &MySQLError{Number: ER_DUP_ENTRY},
+				&MySQLError{Number: ER_OPTION_PREVENTS_STATEMENT, Message: "The MySQL server is running with the --secure-file-priv option so it cannot execute this statement"}},
+		},
+		{"IsConnectionLost", IsConnectionLost,
+			[]error{driver.ErrBadConn, wrap(errInvalidConn), &net.OpError{Op: "read", Err: errors.New("reset")},
+				&MySQLError{Number: CR_SERVER_GONE_ERROR}, &MySQLError{Number: ER_CONNECTION_KILLED}, connLost},
+			[]error{nil, errMalformPkt, &MySQLError{Number: ER_DUP_ENTRY, SQLState: [5]byte{'2', '3', '0', '0', '0'}}},
+		},
+	}
+
+	for _, tst := range predicateTests {
+		for _, err := range tst.match {
+			if !tst.predicate(err) {
+				t.Errorf("%s(%v) should be true", tst.name, err)
+			}
+		}
+		for _, err := range tst.noMatch {
+			if tst.predicate(err) {
+				t.Errorf("%s(%v) should be false", tst.name, err)
+			}
+		}
+	}
+}
+
+func TestWarningsWithoutNotes(t *testing.T) {
+	warnings := MySQLWarnings{
+		{Level: "Note", Code: "1051", Message: "Unknown table 'test'"},
+		{Level: "Warning", Code: "1265", Message: "Data truncated for column 'b' at row 1"},
+		{Level: "Error", Code: "1048", Message: "Column 'a' cannot be null"},
+	}
+
+	filtered := warnings.withoutNotes()
+	if len(filtered) != 2 || filtered[0].Code != "1265" || filtered[1].Code != "1048" {
+		t.Errorf("unexpected warnings %v", filtered)
+	}
+	if len(warnings) != 3 || warnings[0].Code != "1051" {
+		t.Errorf("original warnings were modified: %v", warnings)
+	}
+	if filtered = warnings[:1].withoutNotes(); len(filtered) != 0 {
+		t.Errorf("expected no warnings, got %v", filtered)
+	}
+}
+
+func TestPrepareWarnings(t *testing.T) {
+	connector, err := NewConnector("user@tcp(server:3306)/dbname?warnings=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var queries []string
+	connector.Dialer = fakeDialer(func(query string) [][]byte {
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(query, "PREPARE "):
+			// 1 parameter, 1 column and 1 warning
+			return append(append([][]byte{{iOK, 1, 0, 0, 0, 1, 0, 1, 0, 0, 1, 0}},
+				fakeResultSet([]string{"?"})[1:3]...), fakeResultSet([]string{"name"})[1:3]...)
+		case query == "SHOW WARNINGS":
+			return fakeResultSet([]string{"Level", "Code", "Message"},
+				[]string{"Warning", "1287", "'VALUES function' is deprecated"})
+		case query == "SELECT name":
+			return fakeResultSet([]string{"name"}, []string{"server"})
+		}
+		return nil
+	})
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Prepare("SELECT name WHERE id = ?")
+	if warnings, ok := err.(MySQLWarnings); !ok || len(warnings) != 1 || warnings[0].Code != "1287" {
+		t.Fatalf("expected the warnings of the prepare, got %v", err)
+	}
+
+	// the connection is still in sync
+	var name string
+	if err = db.QueryRow("SELECT name").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := strings.Join(queries, "; ")
+	mu.Unlock()
+	if want := "PREPARE SELECT name WHERE id = ?; SHOW WARNINGS; CLOSE; SELECT name"; got != want {
+		t.Errorf("expected queries %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,231 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+)
+
+// fakeHandler returns the payloads of the response packets to a query.
+// A nil response is sent as OK packet. Prepared statements are passed as
+// "PREPARE query", "EXECUTE" and "CLOSE", the response to CLOSE is ignored.
+// COM_INIT_DB and COM_RESET_CONNECTION are passed as "INIT_DB dbname" and
+// "RESET_CONNECTION".
+type fakeHandler func(query string) [][]byte
+
+// fakeAuth returns the payloads of the response packets to an auth response.
+// The first call gets the user and the auth plugin named by the client, the
+// following calls only the auth switch responses. The exchange ends with an
+// OK or ERR packet. A nil fakeAuth accepts any credentials.
+type fakeAuth func(user, plugin string, authResp []byte) [][]byte
+
+// serveFake completes the handshake as server on conn and answers queries
+// with handle, until the connection is closed
+func serveFake(conn net.Conn, auth fakeAuth, handle fakeHandler) {
+	defer conn.Close()
+
+	flags := clientLongPassword | clientProtocol41 | clientSecureConn | clientPluginAuth
+	if _, err := conn.Write(greeting("8.0.32", flags, 0, testScramble[8:], []byte{0x00}, []byte(authNativePassword+"\x00"))); err != nil {
+		return
+	}
+	data, err := readFakePacket(conn)
+	if err != nil {
+		return
+	}
+	if !serveFakeAuth(conn, auth, data, 2) {
+		return
+	}
+
+	for {
+		data, err := readFakePacket(conn)
+		if err != nil || data[0] == comQuit {
+			return
+		}
+
+		var responses [][]byte
+		switch data[0] {
+		case comChangeUser:
+			if !serveFakeAuth(conn, auth, data, 1) {
+				return
+			}
+			continue
+		case comQuery:
+			query := string(data[1:])
+			if query == "SELECT @@max_allowed_packet" {
+				responses = fakeResultSet([]string{"@@max_allowed_packet"}, []string{"4194304"})
+			} else if handle != nil {
+				responses = handle(query)
+			}
+		case comStmtPrepare:
+			if handle != nil {
+				responses = handle("PREPARE " + string(data[1:]))
+			}
+		case comStmtExecute:
+			if handle != nil {
+				responses = handle("EXECUTE")
+			}
+		case comInitDB:
+			if handle != nil {
+				responses = handle("INIT_DB " + string(data[1:]))
+			}
+		case comResetConnection:
+			if handle != nil {
+				responses = handle("RESET_CONNECTION")
+			}
+		case comStmtClose:
+			if handle != nil {
+				handle("CLOSE")
+			}
+			continue
+		}
+		if responses == nil {
+			responses = [][]byte{okPayload}
+		}
+
+		for i, payload := range responses {
+			if _, err := conn.Write(packet(byte(i+1), payload)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveFakeAuth answers the handshake response or COM_CHANGE_USER packet
+// data with auth, starting with the sequence id seq. It reports whether the
+// client was authenticated.
+func serveFakeAuth(conn net.Conn, auth fakeAuth, data []byte, seq byte) bool {
+	// the response to COM_CHANGE_USER starts a new sequence
+	user, plugin, authResp := parseFakeAuth(data, seq == 1)
+	for {
+		responses := [][]byte{okPayload}
+		if auth != nil {
+			responses = auth(user, plugin, authResp)
+		}
+		for _, payload := range responses {
+			if _, err := conn.Write(packet(seq, payload)); err != nil {
+				return false
+			}
+			seq++
+		}
+		if last := responses[len(responses)-1]; last[0] == iOK || last[0] == iERR {
+			return last[0] == iOK
+		}
+
+		var err error
+		if authResp, err = readFakePacket(conn); err != nil {
+			return false
+		}
+		user, plugin = "", ""
+		seq++
+	}
+}
+
+// parseFakeAuth returns the user, the auth plugin and the auth response of a
+// handshake response or COM_CHANGE_USER packet
+func parseFakeAuth(data []byte, changeUser bool) (user, plugin string, authResp []byte) {
+	// capability flags [4 bytes], max packet size [4 bytes], charset
+	// [1 byte], filler [23 bytes]
+	withDB := clientFlag(data[0])&clientConnectWithDB != 0
+	rest := data[32:]
+	if changeUser {
+		// command [1 byte]
+		withDB = true
+		rest = data[1:]
+	}
+
+	nextString := func() string {
+		end := bytes.IndexByte(rest, 0x00)
+		if end < 0 {
+			end = len(rest)
+		}
+		s := string(rest[:end])
+		rest = rest[len(s):]
+		if len(rest) > 0 {
+			rest = rest[1:]
+		}
+		return s
+	}
+
+	// user [null terminated string]
+	// auth response [length encoded string]
+	// database [null terminated string]
+	// charset [2 bytes], only COM_CHANGE_USER
+	// auth plugin name [null terminated string]
+	user = nextString()
+	authResp, rest = rest[1:1+rest[0]], rest[1+rest[0]:]
+	if withDB {
+		nextString()
+	}
+	if changeUser {
+		rest = rest[2:]
+	}
+	return user, nextString(), authResp
+}
+
+// fakeDialer returns a dial function connecting to fake servers over
+// in-memory pipes
+func fakeDialer(handle fakeHandler) DialContextFunc {
+	return fakeAuthDialer(nil, handle)
+}
+
+// fakeAuthDialer is like fakeDialer, but the fake servers authenticate the
+// clients with auth
+func fakeAuthDialer(auth fakeAuth, handle fakeHandler) DialContextFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFake(server, auth, handle)
+		return client, nil
+	}
+}
+
+func readFakePacket(conn net.Conn) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// fakeResultSet returns the packets of a text result set with string
+// columns. An empty string in a row is sent as NULL.
+func fakeResultSet(columns []string, rows ...[]string) [][]byte {
+	payloads := [][]byte{appendLengthEncodedInteger(nil, uint64(len(columns)))}
+	for _, name := range columns {
+		var def []byte
+		for _, s := range []string{"def", "", "", "", name, name} {
+			def = appendLengthEncodedString(def, s)
+		}
+		def = append(def, 0x0c, collation_utf8_general_ci, 0x00, 0xff, 0x00, 0x00, 0x00, fieldTypeVarString, 0x00, 0x00, 0x00, 0x00, 0x00)
+		payloads = append(payloads, def)
+	}
+	payloads = append(payloads, []byte{iEOF, 0x00, 0x00, 0x02, 0x00})
+
+	for _, row := range rows {
+		var data []byte
+		for _, value := range row {
+			if value == "" {
+				data = append(data, 0xfb)
+			} else {
+				data = appendLengthEncodedString(data, value)
+			}
+		}
+		payloads = append(payloads, data)
+	}
+	return append(payloads, []byte{iEOF, 0x00, 0x00, 0x02, 0x00})
+}
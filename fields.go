@@ -0,0 +1,303 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type mysqlField struct {
+	fieldType byte
+	flags     fieldFlag
+	charSet   uint16
+	length    uint32
+	decimals  byte
+	name      string
+	decoder   TypeDecoder // set if a TypeDecoder is registered for the column
+}
+
+// typeDatabaseName returns the SQL type name of the column.
+// ENUM and SET columns are sent as strings by the server, they can only be
+// told apart by their flags.
+func (mf *mysqlField) typeDatabaseName() string {
+	isBinary := mf.charSet == uint16(collation_binary)
+
+	switch mf.fieldType {
+	case fieldTypeBit:
+		return "BIT"
+	case fieldTypeBLOB:
+		if isBinary {
+			return "BLOB"
+		}
+		return "TEXT"
+	case fieldTypeDate, fieldTypeNewDate:
+		return "DATE"
+	case fieldTypeDateTime:
+		return "DATETIME"
+	case fieldTypeDecimal, fieldTypeNewDecimal:
+		return "DECIMAL"
+	case fieldTypeDouble:
+		return "DOUBLE"
+	case fieldTypeEnum:
+		return "ENUM"
+	case fieldTypeFloat:
+		return "FLOAT"
+	case fieldTypeGeometry:
+		return "GEOMETRY"
+	case fieldTypeInt24:
+		return "MEDIUMINT"
+	case fieldTypeLong:
+		return "INT"
+	case fieldTypeLongBLOB:
+		if isBinary {
+			return "LONGBLOB"
+		}
+		return "LONGTEXT"
+	case fieldTypeLongLong:
+		return "BIGINT"
+	case fieldTypeMediumBLOB:
+		if isBinary {
+			return "MEDIUMBLOB"
+		}
+		return "MEDIUMTEXT"
+	case fieldTypeNULL:
+		return "NULL"
+	case fieldTypeSet:
+		return "SET"
+	case fieldTypeShort:
+		return "SMALLINT"
+	case fieldTypeString:
+		if mf.flags&flagEnum != 0 {
+			return "ENUM"
+		} else if mf.flags&flagSet != 0 {
+			return "SET"
+		}
+		if isBinary {
+			return "BINARY"
+		}
+		return "CHAR"
+	case fieldTypeTime:
+		return "TIME"
+	case fieldTypeTimestamp:
+		return "TIMESTAMP"
+	case fieldTypeTiny:
+		return "TINYINT"
+	case fieldTypeTinyBLOB:
+		if isBinary {
+			return "TINYBLOB"
+		}
+		return "TINYTEXT"
+	case fieldTypeVarChar, fieldTypeVarString:
+		if mf.flags&flagEnum != 0 {
+			return "ENUM"
+		} else if mf.flags&flagSet != 0 {
+			return "SET"
+		}
+		if isBinary {
+			return "VARBINARY"
+		}
+		return "VARCHAR"
+	case fieldTypeYear:
+		return "YEAR"
+	default:
+		return ""
+	}
+}
+
+/******************************************************************************
+*                             BIT, SET and ENUM                               *
+******************************************************************************/
+
+// BitField represents the value of a BIT(n) column, which MySQL sends as a
+// big-endian binary string. BitField implements the Scanner interface so
+// it can be used as a scan destination:
+//
+//  var flags BitField
+//  err := db.QueryRow("SELECT flags FROM features WHERE id=?", id).Scan(&flags)
+//  ...
+//  if flags.Valid && flags.Bits[3] {
+//     // bit 3 is set
+//  }
+//
+// Bits[i] is bit i (the least significant bit first). Because the column
+// width is not known to Scan, len(Bits) is the width rounded up to a
+// multiple of 8.
+type BitField struct {
+	Uint64 uint64
+	Bits   []bool
+	Valid  bool // Valid is true if the value is not NULL
+}
+
+// Scan implements the Scanner interface.
+// The value type must be []byte (BIT value, at most 8 bytes), int64 or uint64,
+// otherwise Scan fails.
+func (bf *BitField) Scan(value interface{}) error {
+	var n int
+	switch v := value.(type) {
+	case nil:
+		bf.Uint64, bf.Bits, bf.Valid = 0, nil, false
+		return nil
+	case []byte:
+		if len(v) > 8 {
+			bf.Valid = false
+			return fmt.Errorf("BIT value too long: %d bytes", len(v))
+		}
+		bf.Uint64 = 0
+		for _, b := range v {
+			bf.Uint64 = bf.Uint64<<8 | uint64(b)
+		}
+		n = 8 * len(v)
+	case int64:
+		bf.Uint64, n = uint64(v), 64
+	case uint64:
+		bf.Uint64, n = v, 64
+	default:
+		bf.Valid = false
+		return fmt.Errorf("Can't convert %T to BitField", value)
+	}
+
+	bf.Bits = make([]bool, n)
+	for i := range bf.Bits {
+		bf.Bits[i] = bf.Uint64&(1<<uint(i)) != 0
+	}
+	bf.Valid = true
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+// A valid BitField is sent as unsigned BIGINT, which MySQL converts to BIT.
+func (bf BitField) Value() (driver.Value, error) {
+	if !bf.Valid {
+		return nil, nil
+	}
+	return bf.Uint64, nil
+}
+
+// SetValue represents the value of a SET column.
+// SetValue implements the Scanner interface so it can be used as a scan
+// destination. A nil SetValue is NULL, an empty, non-nil SetValue is the
+// empty set.
+type SetValue []string
+
+// Scan implements the Scanner interface.
+// The value type must be string / []byte (comma separated members),
+// otherwise Scan fails.
+func (sv *SetValue) Scan(value interface{}) error {
+	var str string
+	switch v := value.(type) {
+	case nil:
+		*sv = nil
+		return nil
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	default:
+		return fmt.Errorf("Can't convert %T to SetValue", value)
+	}
+
+	if str == "" {
+		*sv = SetValue{}
+	} else {
+		*sv = strings.Split(str, ",")
+	}
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (sv SetValue) Value() (driver.Value, error) {
+	if sv == nil {
+		return nil, nil
+	}
+	for _, member := range sv {
+		if strings.IndexByte(member, ',') >= 0 {
+			return nil, fmt.Errorf("SET member must not contain a comma: %q", member)
+		}
+	}
+	return strings.Join(sv, ","), nil
+}
+
+// Contains reports whether member is part of the set.
+func (sv SetValue) Contains(member string) bool {
+	for _, m := range sv {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+var errInvalidEnumSetDef = errors.New("Invalid ENUM / SET definition")
+
+// ParseEnumSetValues returns the allowed values of an ENUM or SET column
+// definition like "enum('a','b')" or "set('x','y''z')".
+//
+// The protocol does not transfer the allowed values with the result set
+// metadata. ColumnType.DatabaseTypeName reports "ENUM" / "SET" for such
+// columns; the definition can then be read from the COLUMN_TYPE column of
+// information_schema.COLUMNS:
+//
+//  var def string
+//  err := db.QueryRow("SELECT COLUMN_TYPE FROM information_schema.COLUMNS "+
+//  	"WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND COLUMN_NAME=?",
+//  	table, column).Scan(&def)
+//  ...
+//  values, err := mysql.ParseEnumSetValues(def)
+//
+func ParseEnumSetValues(columnType string) ([]string, error) {
+	i := strings.IndexByte(columnType, '(')
+	if i < 0 || !strings.HasSuffix(columnType, ")") {
+		return nil, errInvalidEnumSetDef
+	}
+	switch strings.ToLower(strings.TrimSpace(columnType[:i])) {
+	case "enum", "set":
+	default:
+		return nil, errInvalidEnumSetDef
+	}
+
+	def := columnType[i+1 : len(columnType)-1]
+	values := []string{}
+	for len(def) > 0 {
+		if def[0] != '\'' {
+			return nil, errInvalidEnumSetDef
+		}
+
+		// quoted value, quotes are escaped by doubling them
+		var value []byte
+		j := 1
+		for {
+			if j >= len(def) {
+				return nil, errInvalidEnumSetDef
+			}
+			if def[j] == '\'' {
+				if j+1 < len(def) && def[j+1] == '\'' {
+					value = append(value, '\'')
+					j += 2
+					continue
+				}
+				break
+			}
+			value = append(value, def[j])
+			j++
+		}
+		values = append(values, string(value))
+
+		def = def[j+1:]
+		if len(def) > 0 {
+			if def[0] != ',' || len(def) == 1 {
+				return nil, errInvalidEnumSetDef
+			}
+			def = def[1:]
+		}
+	}
+	return values, nil
+}
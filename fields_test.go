@@ -0,0 +1,125 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeDatabaseName(t *testing.T) {
+	var typeTests = []struct {
+		field mysqlField
+		name  string
+	}{
+		{mysqlField{fieldType: fieldTypeString, flags: flagEnum}, "ENUM"},
+		{mysqlField{fieldType: fieldTypeString, flags: flagSet}, "SET"},
+		{mysqlField{fieldType: fieldTypeString, charSet: uint16(collation_binary), flags: flagBinary}, "BINARY"},
+		{mysqlField{fieldType: fieldTypeString, charSet: uint16(collation_utf8_general_ci)}, "CHAR"},
+		{mysqlField{fieldType: fieldTypeVarString, charSet: uint16(collation_binary)}, "VARBINARY"},
+		{mysqlField{fieldType: fieldTypeBLOB, charSet: uint16(collation_utf8_general_ci)}, "TEXT"},
+		{mysqlField{fieldType: fieldTypeBit}, "BIT"},
+		{mysqlField{fieldType: fieldTypeTiny}, "TINYINT"},
+		{mysqlField{fieldType: fieldTypeNewDecimal}, "DECIMAL"},
+	}
+
+	for _, tst := range typeTests {
+		if name := tst.field.typeDatabaseName(); name != tst.name {
+			t.Errorf("%+v: expected %s, got %s", tst.field, tst.name, name)
+		}
+	}
+}
+
+func TestScanBitField(t *testing.T) {
+	var bf BitField
+	if err := bf.Scan([]byte{0x01, 0x05}); err != nil {
+		t.Fatal(err)
+	}
+	if !bf.Valid || bf.Uint64 != 0x0105 || len(bf.Bits) != 16 {
+		t.Fatalf("unexpected value %+v", bf)
+	}
+	for i, bit := range bf.Bits {
+		if expected := i == 0 || i == 2 || i == 8; bit != expected {
+			t.Errorf("bit %d: expected %t, got %t", i, expected, bit)
+		}
+	}
+
+	if err := bf.Scan(nil); err != nil || bf.Valid {
+		t.Errorf("expected NULL, got %+v (%v)", bf, err)
+	}
+	if err := bf.Scan(int64(3)); err != nil || bf.Uint64 != 3 || !bf.Bits[1] {
+		t.Errorf("unexpected value %+v (%v)", bf, err)
+	}
+	if err := bf.Scan(make([]byte, 9)); err == nil {
+		t.Error("expected error for 9 byte value")
+	}
+	if v, _ := (BitField{Uint64: 7, Valid: true}).Value(); v != uint64(7) {
+		t.Errorf("expected uint64(7), got %#v", v)
+	}
+}
+
+func TestScanSetValue(t *testing.T) {
+	var scanTests = []struct {
+		in  interface{}
+		out SetValue
+	}{
+		{[]byte("a,b"), SetValue{"a", "b"}},
+		{"a", SetValue{"a"}},
+		{"", SetValue{}},
+		{nil, nil},
+	}
+
+	var sv SetValue
+	for _, tst := range scanTests {
+		if err := sv.Scan(tst.in); err != nil {
+			t.Errorf("%v: unexpected error: %s", tst.in, err.Error())
+		}
+		if !reflect.DeepEqual(sv, tst.out) {
+			t.Errorf("%v: expected %#v, got %#v", tst.in, tst.out, sv)
+		}
+	}
+
+	if v, _ := (SetValue{"a", "b"}).Value(); v != "a,b" {
+		t.Errorf("expected \"a,b\", got %#v", v)
+	}
+	if v, _ := SetValue(nil).Value(); v != nil {
+		t.Errorf("expected nil, got %#v", v)
+	}
+	if _, err := (SetValue{"a,b"}).Value(); err == nil {
+		t.Error("expected error for member containing a comma")
+	}
+}
+
+func TestParseEnumSetValues(t *testing.T) {
+	var defTests = []struct {
+		in  string
+		out []string
+	}{
+		{"enum('a','b')", []string{"a", "b"}},
+		{"set('x','y''z','')", []string{"x", "y'z", ""}},
+		{"ENUM('a,b')", []string{"a,b"}},
+		{"enum()", []string{}},
+	}
+
+	for _, tst := range defTests {
+		out, err := ParseEnumSetValues(tst.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tst.in, err.Error())
+		}
+		if !reflect.DeepEqual(out, tst.out) {
+			t.Errorf("%q: expected %q, got %q", tst.in, tst.out, out)
+		}
+	}
+
+	for _, in := range []string{"varchar(10)", "enum('a'", "enum('a',)", "enum(a)", "enum('a' 'b')"} {
+		if _, err := ParseEnumSetValues(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build go1.18
+// +build go1.18
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+// Packets synthesized after the formats of MySQL 8.0 and MariaDB 10.6, used
+// as seeds
+var (
+	seedGreetingMySQL = greeting(
+		"8.0.32", clientLongPassword|clientProtocol41|clientSecureConn|clientPluginAuth|clientSessionTrack|clientDeprecateEOF, 0,
+		testScramble[8:], []byte{0x00}, []byte(authCachingSHA2Password+"\x00"),
+	)[4:]
+	seedGreetingMariaDB = greeting(
+		"5.5.5-10.6.12-MariaDB-log", clientProtocol41|clientSecureConn|clientPluginAuth, 0x1d,
+		testScramble[8:], []byte{0x00}, []byte(authNativePassword+"\x00"),
+	)[4:]
+
+	seedOK             = []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	seedOKInsert       = []byte{0x00, 0x03, 0xfc, 0xe8, 0x03, 0x02, 0x00, 0x01, 0x00}
+	seedOKInfo         = []byte("\x00\x01\x00\x02\x00\x00\x00(Rows matched: 1  Changed: 1  Warnings: 0")
+	seedOKSessionState = []byte("\x00\x00\x00\x02\x40\x00\x00\x00\x11\x00\x0f\x0a\x61\x75\x74\x6f\x63\x6f\x6d\x6d\x69\x74\x03\x4f\x46\x46")
+	seedEOF            = []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	seedOKEOF          = []byte{0xfe, 0x00, 0x00, 0x22, 0x00, 0x00, 0x00}
+	seedErr            = []byte("\xff\x15\x04#28000Access denied for user 'root'@'localhost' (using password: YES)")
+	seedErrNoState     = []byte("\xff\x10\x04Too many connections")
+
+	seedColumnID   = []byte("\x03def\x06gotest\x04test\x04test\x02id\x02id\x0c\x3f\x00\x0b\x00\x00\x00\x03\x03\x42\x00\x00\x00")
+	seedColumnName = []byte("\x03def\x06gotest\x04test\x04test\x04name\x04name\x0c\xff\x00\x00\x04\x00\x00\xfd\x00\x00\x00\x00\x00")
+	seedColumnTime = []byte("\x03def\x00\x00\x00\x05NOW()\x00\x0c\x3f\x00\x13\x00\x00\x00\x0c\x81\x00\x00\x00\x00")
+
+	seedTextRow   = []byte("\x0242\x05gopher\x132024-05-01 12:34:56")
+	seedTextNull  = []byte("\x0242\xfb\xfb")
+	seedBinaryRow = []byte{
+		0x00, 0x00, 0x00, // header, NULL bitmap
+		0x2a, 0x00, 0x00, 0x00, // INT 42
+		0x06, 'g', 'o', 'p', 'h', 'e', 'r', // VARCHAR
+		0x07, 0xe8, 0x07, 0x05, 0x01, 0x0c, 0x22, 0x38, // DATETIME
+		0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x03, 0x04, // TIME
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, // DOUBLE 1.0
+	}
+
+	seedPrepareOK = []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+	seedAuthSwitch   = append(append([]byte("\xfecaching_sha2_password\x00"), testScramble...), 0x00)
+	seedAuthMoreData = []byte{iAuthMoreData, cachingSHA2FastAuthSuccess}
+)
+
+// Packets of a MySQL 5.5 server from the hex dumps in the protocol
+// documentation, used as seeds. The OK packet of the documentation is seedOK.
+var (
+	// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeV10
+	seedDocGreeting = []byte{
+		0x0a, 0x35, 0x2e, 0x35, 0x2e, 0x32, 0x2d, 0x6d, 0x32, 0x00, 0x0b, 0x00,
+		0x00, 0x00, 0x64, 0x76, 0x48, 0x40, 0x49, 0x2d, 0x43, 0x4a, 0x00, 0xff, 0xf7, 0x08, 0x02, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, 0x34, 0x64,
+		0x7c, 0x63, 0x5a, 0x77, 0x6b, 0x34, 0x5e, 0x5d, 0x3a, 0x00,
+	}
+
+	// http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-ERR_Packet
+	seedDocErr = []byte{
+		0xff, 0x48, 0x04, 0x23, 0x48, 0x59, 0x30, 0x30, 0x30, 0x4e, 0x6f, 0x20, 0x74, 0x61, 0x62, 0x6c,
+		0x65, 0x73, 0x20, 0x75, 0x73, 0x65, 0x64,
+	}
+
+	// result set of "select @@version_comment limit 1"
+	// http://dev.mysql.com/doc/internals/en/com-query-response.html#packet-ProtocolText::Resultset
+	seedDocColumn = []byte{
+		0x03, 0x64, 0x65, 0x66, 0x00, 0x00, 0x00, 0x11, 0x40, 0x40, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+		0x6e, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x00, 0x0c, 0x08, 0x00, 0x1c, 0x00, 0x00,
+		0x00, 0xfd, 0x00, 0x00, 0x1f, 0x00, 0x00,
+	}
+	seedDocTextRow = []byte{
+		0x1c, 0x4d, 0x79, 0x53, 0x51, 0x4c, 0x20, 0x43, 0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79,
+		0x20, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x20, 0x28, 0x47, 0x50, 0x4c, 0x29,
+	}
+)
+
+// fuzzConn returns a connection which reads the payloads as packets
+func fuzzConn(tb testing.TB, flags clientFlag, payloads ...[]byte) *mysqlConn {
+	// malformed packets are logged
+	logger := errLog
+	errLog = log.New(ioutil.Discard, "", 0)
+	tb.Cleanup(func() { errLog = logger })
+
+	mc, conn := newMockConn(flags)
+	mc.cfg.passwd = "secret"
+	for i, payload := range payloads {
+		conn.queued = append(conn.queued, packet(byte(i), payload))
+	}
+	return mc
+}
+
+// checkMalformed fails if a malformed packet didn't close the connection
+func checkMalformed(t *testing.T, mc *mysqlConn, err error) {
+	if err == errMalformPkt && mc.IsValid() {
+		t.Error("connection not closed after a malformed packet")
+	}
+}
+
+func FuzzReadInitPacket(f *testing.F) {
+	f.Add(seedGreetingMySQL)
+	f.Add(seedGreetingMariaDB)
+	f.Add(seedDocGreeting)
+	f.Add(seedErr)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		mc := fuzzConn(t, 0, data)
+		if cipher, err := mc.readInitPacket(); err == nil && len(cipher) != 8 && len(cipher) != 20 {
+			t.Errorf("unexpected cipher length %d", len(cipher))
+		}
+	})
+}
+
+func FuzzReadResultSetHeaderPacket(f *testing.F) {
+	flags := uint32(clientSessionTrack)
+	f.Add(seedOK, flags)
+	f.Add(seedOKInsert, flags)
+	f.Add(seedOKInfo, uint32(0))
+	f.Add(seedOKSessionState, flags)
+	f.Add(seedErr, flags)
+	f.Add(seedErrNoState, flags)
+	f.Add(seedDocErr, flags)
+	f.Add([]byte{0x03}, flags)
+	f.Add([]byte{0xfc, 0x00, 0x01}, flags)
+	f.Fuzz(func(t *testing.T, data []byte, flags uint32) {
+		if len(data) == 0 || data[0] == iLocalInFile {
+			return
+		}
+		mc := fuzzConn(t, clientFlag(flags), data)
+		_, err := mc.readResultSetHeaderPacket()
+		checkMalformed(t, mc, err)
+	})
+}
+
+func FuzzReadEOFPacket(f *testing.F) {
+	f.Add(seedEOF, false)
+	f.Add(seedOKEOF, true)
+	f.Add(seedOKSessionState, true)
+	f.Fuzz(func(t *testing.T, data []byte, deprecateEOF bool) {
+		if len(data) == 0 {
+			return
+		}
+		var flags clientFlag
+		if deprecateEOF {
+			flags = clientDeprecateEOF | clientSessionTrack
+		}
+		mc := fuzzConn(t, flags)
+		_, err := mc.readEOFPacket(data)
+		checkMalformed(t, mc, err)
+	})
+}
+
+func FuzzReadColumns(f *testing.F) {
+	f.Add(seedColumnID, false)
+	f.Add(seedColumnName, true)
+	f.Add(seedColumnTime, false)
+	f.Add(seedDocColumn, false)
+	f.Add(seedEOF, false)
+	f.Fuzz(func(t *testing.T, data []byte, deprecateEOF bool) {
+		if len(data) == 0 {
+			return
+		}
+		var flags clientFlag
+		if deprecateEOF {
+			flags = clientDeprecateEOF
+		}
+		mc := fuzzConn(t, flags, data, seedEOF)
+		columns, err := mc.readColumns(1)
+		checkMalformed(t, mc, err)
+		if err == nil && len(columns) != 1 {
+			t.Errorf("expected 1 column, got %d", len(columns))
+		}
+	})
+}
+
+func FuzzTextRowsReadRow(f *testing.F) {
+	f.Add(seedTextRow, true)
+	f.Add(seedTextNull, false)
+	f.Add(seedDocTextRow, false)
+	f.Add(seedEOF, false)
+	f.Add(seedErr, false)
+	f.Fuzz(func(t *testing.T, data []byte, parseTime bool) {
+		if len(data) == 0 {
+			return
+		}
+		mc := fuzzConn(t, 0, data)
+		mc.parseTime = parseTime
+		rows := &textRows{mysqlRows{mc: mc, columns: []mysqlField{
+			{fieldType: fieldTypeLongLong},
+			{fieldType: fieldTypeVarString},
+			{fieldType: fieldTypeDateTime},
+		}}}
+		err := rows.readRow(make([]driver.Value, len(rows.columns)))
+		checkMalformed(t, mc, err)
+	})
+}
+
+func FuzzBinaryRowsReadRow(f *testing.F) {
+	f.Add(seedBinaryRow, true)
+	f.Add(seedBinaryRow, false)
+	f.Add(seedEOF, false)
+	f.Add(seedErr, false)
+	f.Add([]byte{0x00, 0x1c, 0x00}, false)
+	f.Fuzz(func(t *testing.T, data []byte, parseTime bool) {
+		if len(data) == 0 {
+			return
+		}
+		mc := fuzzConn(t, 0, data)
+		mc.parseTime = parseTime
+		rows := &binaryRows{mysqlRows{mc: mc, columns: []mysqlField{
+			{fieldType: fieldTypeLong},
+			{fieldType: fieldTypeVarString},
+			{fieldType: fieldTypeDateTime},
+			{fieldType: fieldTypeTime},
+			{fieldType: fieldTypeDouble},
+			{fieldType: fieldTypeTiny},
+			{fieldType: fieldTypeShort},
+			{fieldType: fieldTypeLongLong, flags: flagUnsigned},
+			{fieldType: fieldTypeFloat},
+			{fieldType: fieldTypeDate},
+			{fieldType: fieldTypeNewDecimal},
+		}}}
+		err := rows.readRow(make([]driver.Value, len(rows.columns)))
+		checkMalformed(t, mc, err)
+	})
+}
+
+func FuzzReadPrepareResultPacket(f *testing.F) {
+	f.Add(seedPrepareOK)
+	f.Add(seedErr)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		mc := fuzzConn(t, 0, data)
+		stmt := &mysqlStmt{mc: mc}
+		_, err := stmt.readPrepareResultPacket()
+		checkMalformed(t, mc, err)
+	})
+}
+
+func FuzzParseSessionState(f *testing.F) {
+	f.Add(seedOKSessionState[8:])
+	f.Add([]byte{0x05, 0x03, 0x03, 0x00, 0x01, 'x'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseSessionState(data)
+	})
+}
+
+func FuzzHandleAuthResult(f *testing.F) {
+	f.Add(seedOK)
+	f.Add(seedErr)
+	f.Add(seedDocErr)
+	f.Add([]byte{iEOF})
+	f.Add(seedAuthSwitch)
+	f.Add(seedAuthMoreData)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		mc := fuzzConn(t, clientPluginAuth, data)
+		err := mc.handleAuthResult(authCachingSHA2Password, testScramble)
+		checkMalformed(t, mc, err)
+	})
+}
@@ -0,0 +1,385 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package geometry provides Go types for MySQL spatial values.
+//
+// MySQL sends GEOMETRY values in its internal format: a 4 byte little-endian
+// SRID followed by the Well-Known Binary (WKB) representation.
+// All types of this package implement the sql.Scanner and driver.Valuer
+// interfaces, so they can be used directly as scan destination and as
+// query parameters:
+//
+//  var p geometry.Point
+//  err := db.QueryRow("SELECT location FROM shops WHERE id=?", id).Scan(&p)
+//  ...
+//  _, err = db.Exec("INSERT INTO shops (location) VALUES (?)", geometry.Point{X: 13.4, Y: 52.5})
+//
+// Use Geom to scan values of unknown type or to keep the SRID.
+package geometry
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+)
+
+// Type is the WKB type code of a geometry.
+type Type uint32
+
+// WKB geometry types
+const (
+	PointType              Type = 1
+	LineStringType         Type = 2
+	PolygonType            Type = 3
+	MultiPointType         Type = 4
+	MultiLineStringType    Type = 5
+	MultiPolygonType       Type = 6
+	GeometryCollectionType Type = 7
+)
+
+func (t Type) String() string {
+	switch t {
+	case PointType:
+		return "POINT"
+	case LineStringType:
+		return "LINESTRING"
+	case PolygonType:
+		return "POLYGON"
+	case MultiPointType:
+		return "MULTIPOINT"
+	case MultiLineStringType:
+		return "MULTILINESTRING"
+	case MultiPolygonType:
+		return "MULTIPOLYGON"
+	case GeometryCollectionType:
+		return "GEOMETRYCOLLECTION"
+	}
+	return "UNKNOWN(" + strconv.FormatUint(uint64(t), 10) + ")"
+}
+
+// Geometry is implemented by all geometry types of this package.
+type Geometry interface {
+	// Type returns the WKB type code.
+	Type() Type
+
+	// WKT returns the Well-Known Text representation, e.g. "POINT(1 2)".
+	WKT() string
+
+	appendWKB(b []byte) []byte
+	appendWKT(b []byte) []byte
+}
+
+// Point is a single coordinate.
+type Point struct {
+	X, Y float64
+}
+
+// LineString is a curve of two or more points.
+type LineString []Point
+
+// Polygon is a surface defined by its rings. The first ring is the exterior
+// ring, all following rings are interior rings (holes).
+// Rings must be closed, i.e. the first and the last point are equal.
+type Polygon []LineString
+
+// MultiPoint is a collection of points.
+type MultiPoint []Point
+
+// MultiLineString is a collection of line strings.
+type MultiLineString []LineString
+
+// MultiPolygon is a collection of polygons.
+type MultiPolygon []Polygon
+
+// GeometryCollection is a collection of geometries of any type.
+type GeometryCollection []Geometry
+
+func (Point) Type() Type              { return PointType }
+func (LineString) Type() Type         { return LineStringType }
+func (Polygon) Type() Type            { return PolygonType }
+func (MultiPoint) Type() Type         { return MultiPointType }
+func (MultiLineString) Type() Type    { return MultiLineStringType }
+func (MultiPolygon) Type() Type       { return MultiPolygonType }
+func (GeometryCollection) Type() Type { return GeometryCollectionType }
+
+/******************************************************************************
+*                               Well-Known Text                               *
+******************************************************************************/
+
+func (p Point) WKT() string               { return string(p.appendWKT(nil)) }
+func (ls LineString) WKT() string         { return string(ls.appendWKT(nil)) }
+func (pg Polygon) WKT() string            { return string(pg.appendWKT(nil)) }
+func (mp MultiPoint) WKT() string         { return string(mp.appendWKT(nil)) }
+func (ml MultiLineString) WKT() string    { return string(ml.appendWKT(nil)) }
+func (mp MultiPolygon) WKT() string       { return string(mp.appendWKT(nil)) }
+func (gc GeometryCollection) WKT() string { return string(gc.appendWKT(nil)) }
+
+func (p Point) String() string               { return p.WKT() }
+func (ls LineString) String() string         { return ls.WKT() }
+func (pg Polygon) String() string            { return pg.WKT() }
+func (mp MultiPoint) String() string         { return mp.WKT() }
+func (ml MultiLineString) String() string    { return ml.WKT() }
+func (mp MultiPolygon) String() string       { return mp.WKT() }
+func (gc GeometryCollection) String() string { return gc.WKT() }
+
+func appendCoords(b []byte, p Point) []byte {
+	b = strconv.AppendFloat(b, p.X, 'g', -1, 64)
+	b = append(b, ' ')
+	return strconv.AppendFloat(b, p.Y, 'g', -1, 64)
+}
+
+func appendPoints(b []byte, points []Point, parens bool) []byte {
+	b = append(b, '(')
+	for i, p := range points {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if parens {
+			b = append(b, '(')
+		}
+		b = appendCoords(b, p)
+		if parens {
+			b = append(b, ')')
+		}
+	}
+	return append(b, ')')
+}
+
+func appendRings(b []byte, rings []LineString) []byte {
+	b = append(b, '(')
+	for i, ring := range rings {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendPoints(b, ring, false)
+	}
+	return append(b, ')')
+}
+
+func (p Point) appendWKT(b []byte) []byte {
+	b = append(b, "POINT("...)
+	b = appendCoords(b, p)
+	return append(b, ')')
+}
+
+func (ls LineString) appendWKT(b []byte) []byte {
+	if len(ls) == 0 {
+		return append(b, "LINESTRING EMPTY"...)
+	}
+	return appendPoints(append(b, "LINESTRING"...), ls, false)
+}
+
+func (pg Polygon) appendWKT(b []byte) []byte {
+	if len(pg) == 0 {
+		return append(b, "POLYGON EMPTY"...)
+	}
+	return appendRings(append(b, "POLYGON"...), pg)
+}
+
+func (mp MultiPoint) appendWKT(b []byte) []byte {
+	if len(mp) == 0 {
+		return append(b, "MULTIPOINT EMPTY"...)
+	}
+	return appendPoints(append(b, "MULTIPOINT"...), mp, true)
+}
+
+func (ml MultiLineString) appendWKT(b []byte) []byte {
+	if len(ml) == 0 {
+		return append(b, "MULTILINESTRING EMPTY"...)
+	}
+	return appendRings(append(b, "MULTILINESTRING"...), ml)
+}
+
+func (mp MultiPolygon) appendWKT(b []byte) []byte {
+	if len(mp) == 0 {
+		return append(b, "MULTIPOLYGON EMPTY"...)
+	}
+	b = append(b, "MULTIPOLYGON("...)
+	for i, pg := range mp {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendRings(b, pg)
+	}
+	return append(b, ')')
+}
+
+// appendWKT formats nil members as empty GeometryCollections.
+func (gc GeometryCollection) appendWKT(b []byte) []byte {
+	if len(gc) == 0 {
+		return append(b, "GEOMETRYCOLLECTION EMPTY"...)
+	}
+	b = append(b, "GEOMETRYCOLLECTION("...)
+	for i, g := range gc {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if g == nil {
+			g = GeometryCollection(nil)
+		}
+		b = g.appendWKT(b)
+	}
+	return append(b, ')')
+}
+
+/******************************************************************************
+*                         sql.Scanner / driver.Valuer                         *
+******************************************************************************/
+
+// Geom is a geometry of any type together with its spatial reference
+// system identifier. A nil Geometry represents NULL.
+type Geom struct {
+	SRID     uint32
+	Geometry Geometry
+}
+
+// Scan implements the Scanner interface.
+func (g *Geom) Scan(src interface{}) (err error) {
+	if src == nil {
+		g.SRID, g.Geometry = 0, nil
+		return nil
+	}
+	data, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("geometry: can't convert %T to Geom", src)
+	}
+	g.SRID, g.Geometry, err = Decode(data)
+	return
+}
+
+// Value implements the driver Valuer interface.
+func (g Geom) Value() (driver.Value, error) {
+	if g.Geometry == nil {
+		return nil, nil
+	}
+	return Encode(g.SRID, g.Geometry), nil
+}
+
+// scan decodes src, which must be a geometry of type t.
+func scan(src interface{}, t Type) (Geometry, error) {
+	data, ok := src.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("geometry: can't convert %T to %s", src, t)
+	}
+	_, g, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if g.Type() != t {
+		return nil, fmt.Errorf("geometry: can't convert %s to %s", g.Type(), t)
+	}
+	return g, nil
+}
+
+// Scan implements the Scanner interface.
+func (p *Point) Scan(src interface{}) error {
+	g, err := scan(src, PointType)
+	if err == nil {
+		*p = g.(Point)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (ls *LineString) Scan(src interface{}) error {
+	if src == nil {
+		*ls = nil
+		return nil
+	}
+	g, err := scan(src, LineStringType)
+	if err == nil {
+		*ls = g.(LineString)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (pg *Polygon) Scan(src interface{}) error {
+	if src == nil {
+		*pg = nil
+		return nil
+	}
+	g, err := scan(src, PolygonType)
+	if err == nil {
+		*pg = g.(Polygon)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (mp *MultiPoint) Scan(src interface{}) error {
+	if src == nil {
+		*mp = nil
+		return nil
+	}
+	g, err := scan(src, MultiPointType)
+	if err == nil {
+		*mp = g.(MultiPoint)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (ml *MultiLineString) Scan(src interface{}) error {
+	if src == nil {
+		*ml = nil
+		return nil
+	}
+	g, err := scan(src, MultiLineStringType)
+	if err == nil {
+		*ml = g.(MultiLineString)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (mp *MultiPolygon) Scan(src interface{}) error {
+	if src == nil {
+		*mp = nil
+		return nil
+	}
+	g, err := scan(src, MultiPolygonType)
+	if err == nil {
+		*mp = g.(MultiPolygon)
+	}
+	return err
+}
+
+// Scan implements the Scanner interface. NULL is scanned as nil.
+func (gc *GeometryCollection) Scan(src interface{}) error {
+	if src == nil {
+		*gc = nil
+		return nil
+	}
+	g, err := scan(src, GeometryCollectionType)
+	if err == nil {
+		*gc = g.(GeometryCollection)
+	}
+	return err
+}
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (p Point) Value() (driver.Value, error) { return Encode(0, p), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (ls LineString) Value() (driver.Value, error) { return Encode(0, ls), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (pg Polygon) Value() (driver.Value, error) { return Encode(0, pg), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (mp MultiPoint) Value() (driver.Value, error) { return Encode(0, mp), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (ml MultiLineString) Value() (driver.Value, error) { return Encode(0, ml), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (mp MultiPolygon) Value() (driver.Value, error) { return Encode(0, mp), nil }
+
+// Value implements the driver Valuer interface. The SRID is 0.
+func (gc GeometryCollection) Value() (driver.Value, error) { return Encode(0, gc), nil }
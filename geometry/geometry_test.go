@@ -0,0 +1,146 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package geometry
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+var square = LineString{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+var hole = LineString{{2, 2}, {3, 2}, {3, 3}, {2, 2}}
+
+var geometryTests = []struct {
+	geometry Geometry
+	wkt      string
+}{
+	{Point{1, -2.5}, "POINT(1 -2.5)"},
+	{LineString{{0, 0}, {1, 1}}, "LINESTRING(0 0,1 1)"},
+	{Polygon{square, hole}, "POLYGON((0 0,10 0,10 10,0 10,0 0),(2 2,3 2,3 3,2 2))"},
+	{MultiPoint{{1, 2}, {3, 4}}, "MULTIPOINT((1 2),(3 4))"},
+	{MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}, "MULTILINESTRING((0 0,1 1),(2 2,3 3))"},
+	{MultiPolygon{{square}, {square, hole}}, "MULTIPOLYGON(((0 0,10 0,10 10,0 10,0 0)),((0 0,10 0,10 10,0 10,0 0),(2 2,3 2,3 3,2 2)))"},
+	{GeometryCollection{Point{1, 2}, GeometryCollection{LineString{{0, 0}, {1, 1}}}}, "GEOMETRYCOLLECTION(POINT(1 2),GEOMETRYCOLLECTION(LINESTRING(0 0,1 1)))"},
+	{GeometryCollection{}, "GEOMETRYCOLLECTION EMPTY"},
+}
+
+func TestWKT(t *testing.T) {
+	for _, tst := range geometryTests {
+		if wkt := tst.geometry.WKT(); wkt != tst.wkt {
+			t.Errorf("expected %s, got %s", tst.wkt, wkt)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tst := range geometryTests {
+		data := Encode(4326, tst.geometry)
+		srid, g, err := Decode(data)
+		if err != nil {
+			t.Errorf("%s: %s", tst.wkt, err.Error())
+			continue
+		}
+		if srid != 4326 {
+			t.Errorf("%s: expected SRID 4326, got %d", tst.wkt, srid)
+		}
+		if !reflect.DeepEqual(g, tst.geometry) {
+			t.Errorf("%s: expected %#v, got %#v", tst.wkt, tst.geometry, g)
+		}
+	}
+}
+
+func TestDecodeMySQL(t *testing.T) {
+	// SELECT ST_GeomFromText('POINT(1 2)', 4326)
+	data, _ := hex.DecodeString("E61000000101000000000000000000F03F0000000000000040")
+	srid, g, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srid != 4326 || g != (Point{1, 2}) {
+		t.Errorf("expected SRID 4326 POINT(1 2), got %d %s", srid, g.WKT())
+	}
+	if !bytes.Equal(Encode(srid, g), data) {
+		t.Errorf("expected %x, got %x", data, Encode(srid, g))
+	}
+
+	// big-endian WKB
+	data, _ = hex.DecodeString("00000000" + "00" + "00000001" + "4000000000000000" + "4010000000000000")
+	if _, g, err = Decode(data); err != nil || g != (Point{2, 4}) {
+		t.Errorf("expected POINT(2 4), got %v (%v)", g, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid := Encode(0, MultiPolygon{{square, hole}})
+	for i := 0; i < len(valid); i++ {
+		if _, _, err := Decode(valid[:i]); err == nil {
+			t.Errorf("expected error for truncated data of length %d", i)
+		}
+	}
+
+	invalid := [][]byte{
+		append(Encode(0, Point{1, 2}), 0x00),                   // trailing data
+		{0, 0, 0, 0, 2, 1, 0, 0, 0},                            // byte order
+		{0, 0, 0, 0, 1, 8, 0, 0, 0},                            // type
+		{0, 0, 0, 0, 1, 2, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},    // count
+		{0, 0, 0, 0, 1, 4, 0, 0, 0, 1, 0, 0, 0, 1, 2, 0, 0, 0}, // member type
+	}
+	for _, data := range invalid {
+		if _, _, err := Decode(data); err == nil {
+			t.Errorf("%x: expected error", data)
+		}
+	}
+
+	g := Geometry(GeometryCollection{})
+	for i := 0; i <= maxNesting; i++ {
+		g = GeometryCollection{g}
+	}
+	nested := Encode(0, g)
+	if _, _, err := Decode(nested); err != errNesting {
+		t.Errorf("expected errNesting, got %v", err)
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	var p Point
+	if err := p.Scan(Encode(0, Point{3, 4})); err != nil || p != (Point{3, 4}) {
+		t.Errorf("expected POINT(3 4), got %s (%v)", p, err)
+	}
+	if err := p.Scan(Encode(0, LineString{{0, 0}, {1, 1}})); err == nil {
+		t.Error("expected error when scanning a LINESTRING into a Point")
+	}
+	if err := p.Scan(nil); err == nil {
+		t.Error("expected error when scanning NULL into a Point")
+	}
+
+	var pg Polygon
+	if err := pg.Scan(nil); err != nil || pg != nil {
+		t.Errorf("expected nil, got %v (%v)", pg, err)
+	}
+
+	var g Geom
+	if err := g.Scan(Encode(3857, MultiPoint{{1, 2}})); err != nil {
+		t.Fatal(err)
+	}
+	if g.SRID != 3857 || g.Geometry.WKT() != "MULTIPOINT((1 2))" {
+		t.Errorf("unexpected value %d %s", g.SRID, g.Geometry.WKT())
+	}
+	v, err := g.Value()
+	if err != nil || !bytes.Equal(v.([]byte), Encode(3857, MultiPoint{{1, 2}})) {
+		t.Errorf("unexpected value %x (%v)", v, err)
+	}
+	if err := g.Scan(nil); err != nil || g.Geometry != nil {
+		t.Errorf("expected NULL, got %v (%v)", g.Geometry, err)
+	}
+	if v, _ := g.Value(); v != nil {
+		t.Errorf("expected nil, got %x", v)
+	}
+}
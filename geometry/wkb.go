@@ -0,0 +1,332 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2014 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package geometry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	errShortWKB = errors.New("geometry: WKB data too short")
+	errNesting  = errors.New("geometry: GeometryCollection nested too deeply")
+)
+
+// maxNesting limits the depth of nested GeometryCollections when decoding.
+const maxNesting = 32
+
+// Decode decodes a value in MySQL's internal geometry format, which is the
+// 4 byte little-endian SRID followed by the WKB representation.
+func Decode(data []byte) (srid uint32, g Geometry, err error) {
+	if len(data) < 4 {
+		return 0, nil, errShortWKB
+	}
+	srid = binary.LittleEndian.Uint32(data[:4])
+	g, err = DecodeWKB(data[4:])
+	return
+}
+
+// Encode encodes g in MySQL's internal geometry format with the given SRID.
+func Encode(srid uint32, g Geometry) []byte {
+	b := make([]byte, 4, 64)
+	binary.LittleEndian.PutUint32(b, srid)
+	return g.appendWKB(b)
+}
+
+// DecodeWKB decodes the Well-Known Binary representation of a geometry.
+// Both byte orders are supported.
+func DecodeWKB(data []byte) (Geometry, error) {
+	r := wkbReader{data: data}
+	g, err := r.readGeometry(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("geometry: %d trailing bytes after WKB", len(data)-r.pos)
+	}
+	return g, nil
+}
+
+// EncodeWKB returns the little-endian Well-Known Binary representation of g.
+func EncodeWKB(g Geometry) []byte {
+	return g.appendWKB(nil)
+}
+
+/******************************************************************************
+*                                  Decoding                                   *
+******************************************************************************/
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) readUint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, errShortWKB
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// readCount reads a number of elements and checks it against the remaining
+// data, so corrupt input can not trigger huge allocations.
+func (r *wkbReader) readCount(minSize int) (int, error) {
+	n, err := r.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(minSize) > uint64(len(r.data)-r.pos) {
+		return 0, errShortWKB
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) readPoint() (Point, error) {
+	if len(r.data)-r.pos < 16 {
+		return Point{}, errShortWKB
+	}
+	p := Point{
+		X: math.Float64frombits(r.order.Uint64(r.data[r.pos:])),
+		Y: math.Float64frombits(r.order.Uint64(r.data[r.pos+8:])),
+	}
+	r.pos += 16
+	return p, nil
+}
+
+func (r *wkbReader) readPoints() ([]Point, error) {
+	n, err := r.readCount(16)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]Point, n)
+	for i := range points {
+		if points[i], err = r.readPoint(); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func (r *wkbReader) readRings() ([]LineString, error) {
+	n, err := r.readCount(4)
+	if err != nil {
+		return nil, err
+	}
+	rings := make([]LineString, n)
+	for i := range rings {
+		if rings[i], err = r.readPoints(); err != nil {
+			return nil, err
+		}
+	}
+	return rings, nil
+}
+
+// readHeader reads the byte order and the type of the next geometry.
+func (r *wkbReader) readHeader() (Type, error) {
+	if r.pos >= len(r.data) {
+		return 0, errShortWKB
+	}
+	switch r.data[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return 0, fmt.Errorf("geometry: invalid WKB byte order %d", r.data[r.pos])
+	}
+	r.pos++
+	t, err := r.readUint32()
+	return Type(t), err
+}
+
+// readMember reads a member of a Multi* geometry, which must be of type t.
+func (r *wkbReader) readMember(t Type) error {
+	mt, err := r.readHeader()
+	if err == nil && mt != t {
+		err = fmt.Errorf("geometry: unexpected %s in %s collection", mt, t)
+	}
+	return err
+}
+
+func (r *wkbReader) readGeometry(depth int) (Geometry, error) {
+	t, err := r.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case PointType:
+		return r.readPoint()
+
+	case LineStringType:
+		points, err := r.readPoints()
+		return LineString(points), err
+
+	case PolygonType:
+		rings, err := r.readRings()
+		return Polygon(rings), err
+
+	case MultiPointType:
+		n, err := r.readCount(1 + 4 + 16)
+		if err != nil {
+			return nil, err
+		}
+		mp := make(MultiPoint, n)
+		for i := range mp {
+			if err = r.readMember(PointType); err != nil {
+				return nil, err
+			}
+			if mp[i], err = r.readPoint(); err != nil {
+				return nil, err
+			}
+		}
+		return mp, nil
+
+	case MultiLineStringType:
+		n, err := r.readCount(1 + 4 + 4)
+		if err != nil {
+			return nil, err
+		}
+		ml := make(MultiLineString, n)
+		for i := range ml {
+			if err = r.readMember(LineStringType); err != nil {
+				return nil, err
+			}
+			if ml[i], err = r.readPoints(); err != nil {
+				return nil, err
+			}
+		}
+		return ml, nil
+
+	case MultiPolygonType:
+		n, err := r.readCount(1 + 4 + 4)
+		if err != nil {
+			return nil, err
+		}
+		mp := make(MultiPolygon, n)
+		for i := range mp {
+			if err = r.readMember(PolygonType); err != nil {
+				return nil, err
+			}
+			if mp[i], err = r.readRings(); err != nil {
+				return nil, err
+			}
+		}
+		return mp, nil
+
+	case GeometryCollectionType:
+		if depth >= maxNesting {
+			return nil, errNesting
+		}
+		n, err := r.readCount(1 + 4)
+		if err != nil {
+			return nil, err
+		}
+		gc := make(GeometryCollection, n)
+		for i := range gc {
+			if gc[i], err = r.readGeometry(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return gc, nil
+	}
+
+	return nil, fmt.Errorf("geometry: unsupported WKB type %d", uint32(t))
+}
+
+/******************************************************************************
+*                                  Encoding                                   *
+******************************************************************************/
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendHeader(b []byte, t Type) []byte {
+	return appendUint32(append(b, 1), uint32(t))
+}
+
+func appendPointCoords(b []byte, p Point) []byte {
+	x, y := math.Float64bits(p.X), math.Float64bits(p.Y)
+	return append(b,
+		byte(x), byte(x>>8), byte(x>>16), byte(x>>24),
+		byte(x>>32), byte(x>>40), byte(x>>48), byte(x>>56),
+		byte(y), byte(y>>8), byte(y>>16), byte(y>>24),
+		byte(y>>32), byte(y>>40), byte(y>>48), byte(y>>56),
+	)
+}
+
+func appendPointList(b []byte, points []Point) []byte {
+	b = appendUint32(b, uint32(len(points)))
+	for _, p := range points {
+		b = appendPointCoords(b, p)
+	}
+	return b
+}
+
+func appendRingList(b []byte, rings []LineString) []byte {
+	b = appendUint32(b, uint32(len(rings)))
+	for _, ring := range rings {
+		b = appendPointList(b, ring)
+	}
+	return b
+}
+
+func (p Point) appendWKB(b []byte) []byte {
+	return appendPointCoords(appendHeader(b, PointType), p)
+}
+
+func (ls LineString) appendWKB(b []byte) []byte {
+	return appendPointList(appendHeader(b, LineStringType), ls)
+}
+
+func (pg Polygon) appendWKB(b []byte) []byte {
+	return appendRingList(appendHeader(b, PolygonType), pg)
+}
+
+func (mp MultiPoint) appendWKB(b []byte) []byte {
+	b = appendUint32(appendHeader(b, MultiPointType), uint32(len(mp)))
+	for _, p := range mp {
+		b = p.appendWKB(b)
+	}
+	return b
+}
+
+func (ml MultiLineString) appendWKB(b []byte) []byte {
+	b = appendUint32(appendHeader(b, MultiLineStringType), uint32(len(ml)))
+	for _, ls := range ml {
+		b = ls.appendWKB(b)
+	}
+	return b
+}
+
+func (mp MultiPolygon) appendWKB(b []byte) []byte {
+	b = appendUint32(appendHeader(b, MultiPolygonType), uint32(len(mp)))
+	for _, pg := range mp {
+		b = pg.appendWKB(b)
+	}
+	return b
+}
+
+// appendWKB encodes nil members as empty GeometryCollections.
+func (gc GeometryCollection) appendWKB(b []byte) []byte {
+	b = appendUint32(appendHeader(b, GeometryCollectionType), uint32(len(gc)))
+	for _, g := range gc {
+		if g == nil {
+			g = GeometryCollection(nil)
+		}
+		b = g.appendWKB(b)
+	}
+	return b
+}
@@ -0,0 +1,304 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Replicas wait up to one second for the GTIDs of a read by default
+	defaultGTIDWaitTimeout = time.Second
+
+	// Tokens with more intervals are replaced by @@gtid_executed
+	maxGTIDTokenIntervals = 32
+)
+
+type (
+	gtidTokenKey struct{}
+	waitGTIDsKey struct{}
+)
+
+// GTIDToken records the GTIDs of the writes executed with the context
+// returned by CaptureGTIDs. It is safe for concurrent use.
+type GTIDToken struct {
+	mu    sync.Mutex
+	gtids string
+}
+
+// String returns the recorded GTID set, which can be passed to WaitForGTIDs.
+func (t *GTIDToken) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gtids
+}
+
+// Adds the GTIDs of a transaction. The intervals of each UUID are merged,
+// it reports whether the token has at most maxGTIDTokenIntervals intervals.
+func (t *GTIDToken) add(gtids string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	set, ok := parseGTIDSet(t.gtids)
+	added, addedOK := parseGTIDSet(gtids)
+	if !ok || !addedOK {
+		// keep unknown formats as they are
+		if t.gtids != "" {
+			gtids = t.gtids + "," + gtids
+		}
+		t.gtids = gtids
+		return strings.Count(t.gtids, ",") < maxGTIDTokenIntervals
+	}
+
+	set.add(added)
+	t.gtids = set.String()
+	return set.intervals() <= maxGTIDTokenIntervals
+}
+
+// Replaces the GTIDs by a superset
+func (t *GTIDToken) set(gtids string) {
+	t.mu.Lock()
+	t.gtids = gtids
+	t.mu.Unlock()
+}
+
+// CaptureGTIDs returns a context which records the GTIDs of the statements
+// executed with Exec and of the transactions begun with it in the returned
+// token. The token can be carried by the application, e.g. in a cookie, and
+// passed to WaitForGTIDs to read the writes from a replica.
+//
+// The GTIDs are taken from the session state if session_track_gtids is
+// enabled, otherwise @@gtid_executed is read after each write. The server
+// must run with gtid_mode=ON.
+//
+//  ctx, token := mysql.CaptureGTIDs(ctx)
+//  _, err := db.ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", name, id)
+//  ...
+//  http.SetCookie(w, &http.Cookie{Name: "gtids", Value: token.String()})
+func CaptureGTIDs(ctx context.Context) (context.Context, *GTIDToken) {
+	token := new(GTIDToken)
+	return context.WithValue(ctx, gtidTokenKey{}, token), token
+}
+
+// WaitForGTIDs returns a context which makes a ReplicationConnector wait
+// until the replica has applied the GTID set gtids, before a read is sent to
+// it. If the replica doesn't catch up within the GTIDWaitTimeout of the
+// connector, the read is sent to the primary. Requires MySQL 5.7.5+.
+//
+//  cookie, err := r.Cookie("gtids")
+//  if err == nil {
+//  	ctx = mysql.WaitForGTIDs(ctx, cookie.Value)
+//  }
+//  err = db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = ?", id).Scan(&name)
+func WaitForGTIDs(ctx context.Context, gtids string) context.Context {
+	return context.WithValue(ctx, waitGTIDsKey{}, gtids)
+}
+
+// Returns the token of ctx set by CaptureGTIDs, or nil
+func gtidToken(ctx context.Context) *GTIDToken {
+	token, _ := ctx.Value(gtidTokenKey{}).(*GTIDToken)
+	return token
+}
+
+// captureGTIDs records the GTIDs of the last statement in token, if it is
+// not nil. Statements inside of transactions are recorded by the commit.
+func (mc *mysqlConn) captureGTIDs(token *GTIDToken) {
+	if token == nil || mc.InTransaction() {
+		return
+	}
+
+	if mc.sessionState != nil && mc.sessionState.GTIDs != "" {
+		if token.add(mc.sessionState.GTIDs) {
+			return
+		}
+		// @@gtid_executed is a compact superset of the grown token
+	}
+
+	gtids, err := mc.getSystemVar("gtid_executed")
+	if err != nil {
+		errLog.Print(err)
+		return
+	}
+	if len(gtids) > 0 {
+		token.set(strings.Replace(string(gtids), "\n", "", -1))
+	}
+}
+
+// waitForGTIDs waits until the server has applied the GTID set of ctx,
+// which must be a replica. It reports whether the server caught up within
+// timeout. The deadline of ctx interrupts the wait.
+func (mc *mysqlConn) waitForGTIDs(ctx context.Context, timeout time.Duration) (bool, error) {
+	gtids, _ := ctx.Value(waitGTIDsKey{}).(string)
+	if gtids == "" {
+		return true, nil
+	}
+	if !isGTIDSet(gtids) {
+		return false, errGTIDSet
+	}
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer clearDeadline()
+
+	if timeout <= 0 {
+		timeout = defaultGTIDWaitTimeout
+	}
+	// The timeout is given in whole seconds, 0 would wait forever
+	seconds := int64((timeout + time.Second - 1) / time.Second)
+
+	result, err := mc.queryValue("SELECT WAIT_FOR_EXECUTED_GTID_SET('" + gtids + "', " +
+		strconv.FormatInt(seconds, 10) + ")")
+	if err != nil {
+		return false, err
+	}
+	return string(result) == "0", nil
+}
+
+// isGTIDSet reports whether gtids only consists of the characters of GTID
+// sets (UUIDs, tags, intervals and separators), so it can be quoted safely.
+func isGTIDSet(gtids string) bool {
+	for i := 0; i < len(gtids); i++ {
+		switch c := gtids[i]; {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c == '-', c == ':', c == ',', c == '_', c == ' ', c == '\n':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// gtidSet maps the UUIDs of a GTID set, followed by the tag if any, to
+// their sorted and disjoint intervals of transaction numbers
+type gtidSet map[string][]gtidInterval
+
+type gtidInterval struct {
+	start, end uint64
+}
+
+// parseGTIDSet parses a GTID set like "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7".
+// It reports false for unknown formats.
+func parseGTIDSet(gtids string) (gtidSet, bool) {
+	set := make(gtidSet)
+	for _, elem := range strings.Split(gtids, ",") {
+		// the server inserts newlines after each UUID
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+
+		parts := strings.Split(elem, ":")
+		uuid, key := parts[0], parts[0]
+		if uuid == "" || len(parts) < 2 {
+			return nil, false
+		}
+		for _, part := range parts[1:] {
+			if part == "" {
+				return nil, false
+			}
+			// tags (MySQL 8.3+) start with a letter or underscore
+			if part[0] < '0' || part[0] > '9' {
+				key = uuid + ":" + part
+				continue
+			}
+
+			start, end := part, part
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				start, end = part[:i], part[i+1:]
+			}
+			var iv gtidInterval
+			var err error
+			if iv.start, err = strconv.ParseUint(start, 10, 64); err != nil {
+				return nil, false
+			}
+			if iv.end, err = strconv.ParseUint(end, 10, 64); err != nil || iv.end < iv.start {
+				return nil, false
+			}
+			set[key] = append(set[key], iv)
+		}
+	}
+
+	for key, ivs := range set {
+		set[key] = mergeGTIDIntervals(ivs)
+	}
+	return set, true
+}
+
+// add merges the intervals of other into s
+func (s gtidSet) add(other gtidSet) {
+	for key, ivs := range other {
+		s[key] = mergeGTIDIntervals(append(s[key], ivs...))
+	}
+}
+
+// intervals returns the number of intervals in s
+func (s gtidSet) intervals() int {
+	n := 0
+	for _, ivs := range s {
+		n += len(ivs)
+	}
+	return n
+}
+
+// String returns the GTID set sorted by UUID
+func (s gtidSet) String() string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		for _, iv := range s[key] {
+			b.WriteByte(':')
+			b.WriteString(strconv.FormatUint(iv.start, 10))
+			if iv.end != iv.start {
+				b.WriteByte('-')
+				b.WriteString(strconv.FormatUint(iv.end, 10))
+			}
+		}
+	}
+	return b.String()
+}
+
+// Sorts the intervals and merges overlapping and adjacent ones
+func mergeGTIDIntervals(ivs []gtidInterval) []gtidInterval {
+	if len(ivs) == 0 {
+		return ivs
+	}
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].start < ivs[j].start })
+
+	merged := ivs[:1]
+	for _, iv := range ivs[1:] {
+		last := &merged[len(merged)-1]
+		if iv.start <= last.end+1 {
+			if iv.end > last.end {
+				last.end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
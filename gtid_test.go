@@ -0,0 +1,238 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testGTIDs    = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+	testNewGTIDs = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"
+)
+
+func TestCaptureGTIDsSessionState(t *testing.T) {
+	mc := &mysqlConn{}
+	token := new(GTIDToken)
+
+	mc.sessionState = &SessionState{GTIDs: "3e11fa47-71ca-11e1-9e33-c80aa9429562:7"}
+	mc.captureGTIDs(token)
+	mc.sessionState = &SessionState{GTIDs: "3e11fa47-71ca-11e1-9e33-c80aa9429562:8"}
+	mc.captureGTIDs(token)
+
+	want := "3e11fa47-71ca-11e1-9e33-c80aa9429562:7-8"
+	if token.String() != want {
+		t.Errorf("expected %q, got %q", want, token.String())
+	}
+
+	// not recorded before the commit
+	mc.status = statusInTrans
+	mc.sessionState = &SessionState{GTIDs: "3e11fa47-71ca-11e1-9e33-c80aa9429562:9"}
+	mc.captureGTIDs(token)
+	if token.String() != want {
+		t.Errorf("expected %q inside of transaction, got %q", want, token.String())
+	}
+}
+
+func TestGTIDTokenAdd(t *testing.T) {
+	const (
+		uuidA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+		uuidB = "ab4a1e23-9c8e-11ed-a0b1-0242ac120002"
+	)
+	tests := []struct {
+		gtids, added, want string
+	}{
+		{"", uuidA + ":1-5", uuidA + ":1-5"},
+		{uuidA + ":1-5", uuidA + ":6", uuidA + ":1-6"},
+		{uuidA + ":1-5", uuidA + ":3-9:12", uuidA + ":1-9:12"},
+		{uuidA + ":12", uuidA + ":1-3", uuidA + ":1-3:12"},
+		{uuidB + ":1-3,\n" + uuidA + ":4", uuidA + ":5", uuidA + ":4-5," + uuidB + ":1-3"},
+		{uuidA + ":1-2", uuidA + ":tag_1:1-2:4", uuidA + ":1-2," + uuidA + ":tag_1:1-2:4"},
+		{uuidA + ":1", uuidA + ":5-3", uuidA + ":1," + uuidA + ":5-3"},
+	}
+	for _, tst := range tests {
+		token := &GTIDToken{gtids: tst.gtids}
+		token.add(tst.added)
+		if token.String() != tst.want {
+			t.Errorf("%q + %q: expected %q, got %q", tst.gtids, tst.added, tst.want, token.String())
+		}
+	}
+
+	// interleaved writes of other sessions leave gaps, the token is replaced
+	// by @@gtid_executed when it grows too large
+	mc, conn := newMockConn(0)
+	token := new(GTIDToken)
+	for i := 1; i <= maxGTIDTokenIntervals; i++ {
+		mc.sessionState = &SessionState{GTIDs: uuidA + ":" + strconv.Itoa(2*i)}
+		mc.captureGTIDs(token)
+	}
+	if conn.written.Len() != 0 {
+		t.Errorf("unexpected query %q", conn.written.Bytes())
+	}
+
+	for i, payload := range fakeResultSet([]string{"@@gtid_executed"}, []string{uuidA + ":1-100"}) {
+		conn.queued = append(conn.queued, packet(byte(i+1), payload))
+	}
+	mc.sessionState = &SessionState{GTIDs: uuidA + ":100"}
+	mc.captureGTIDs(token)
+	if want := uuidA + ":1-100"; token.String() != want {
+		t.Errorf("expected %q, got %q", want, token.String())
+	}
+}
+
+func TestCaptureGTIDs(t *testing.T) {
+	connector, err := NewConnector("user@tcp(primary:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var queries []string
+	gtidExecuted := testGTIDs
+	inTrans := []byte{iOK, 0, 0, byte(statusInTrans | statusInAutocommit), 0, 0, 0}
+	connector.Dialer = fakeDialer(func(query string) [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+		switch query {
+		case "SELECT @@gtid_executed":
+			// the server inserts newlines after each UUID
+			return fakeResultSet([]string{"@@gtid_executed"}, []string{"ab4a1e23-9c8e-11ed-a0b1-0242ac120002:1-3,\n" + gtidExecuted})
+		case "START TRANSACTION", "UPDATE t SET a = 2":
+			return [][]byte{inTrans}
+		case "COMMIT":
+			gtidExecuted = testNewGTIDs
+		}
+		return nil
+	})
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx, token := CaptureGTIDs(context.Background())
+	if _, err = db.ExecContext(ctx, "UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ab4a1e23-9c8e-11ed-a0b1-0242ac120002:1-3," + testGTIDs; token.String() != want {
+		t.Errorf("expected %q, got %q", want, token.String())
+	}
+
+	// transactions are recorded by the commit
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE t SET a = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ab4a1e23-9c8e-11ed-a0b1-0242ac120002:1-3," + testNewGTIDs; token.String() != want {
+		t.Errorf("expected %q, got %q", want, token.String())
+	}
+	mu.Lock()
+	got := strings.Join(queries, "; ")
+	mu.Unlock()
+	want := "UPDATE t SET a = 1; SELECT @@gtid_executed; START TRANSACTION; UPDATE t SET a = 2; COMMIT; SELECT @@gtid_executed"
+	if got != want {
+		t.Errorf("expected queries %q, got %q", want, got)
+	}
+
+	// without token
+	if _, err = db.Exec("UPDATE t SET a = 3"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(strings.Join(queries, "; "), "gtid_executed") != 2 {
+		t.Errorf("expected no GTID query without token: %q", queries)
+	}
+
+	if _, err = db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err != errTxOptions {
+		t.Errorf("expected errTxOptions, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = (&mysqlConn{}).BeginTx(canceled, driver.TxOptions{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWaitForGTIDs(t *testing.T) {
+	connector, queries := fakeReplication(t, nil)
+	connector.GTIDWaitTimeout = 1500 * time.Millisecond
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, tst := range []struct {
+		gtids string
+		want  string
+	}{
+		{"", "replica"},
+		{testGTIDs, "replica"},
+		{testNewGTIDs, "primary"},
+	} {
+		var name string
+		if err := db.QueryRowContext(WaitForGTIDs(ctx, tst.gtids), "SELECT name").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name != tst.want {
+			t.Errorf("%q: expected %s, got %s", tst.gtids, tst.want, name)
+		}
+	}
+
+	var waits []string
+	for _, query := range queries() {
+		if strings.HasPrefix(query, "replica: SELECT WAIT") {
+			waits = append(waits, query)
+		}
+	}
+	if len(waits) != 2 || waits[0] != "replica: SELECT WAIT_FOR_EXECUTED_GTID_SET('"+testGTIDs+"', 2)" {
+		t.Errorf("unexpected waits: %q", waits)
+	}
+
+	var name string
+	err := db.QueryRowContext(WaitForGTIDs(ctx, "x', 0) OR SLEEP(10) -- "), "SELECT name").Scan(&name)
+	if err != errGTIDSet {
+		t.Errorf("expected errGTIDSet, got %v", err)
+	}
+}
+
+func TestWaitForGTIDsDeadline(t *testing.T) {
+	connector, _ := fakeReplication(t, nil)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// the replica waits for the whole GTIDWaitTimeout
+	connector.Replicas[0].Dialer = fakeDialer(func(query string) [][]byte {
+		if strings.HasPrefix(query, "SELECT WAIT_FOR_EXECUTED_GTID_SET(") {
+			time.Sleep(time.Second)
+			return fakeResultSet([]string{"wait"}, []string{"1"})
+		}
+		return fakeResultSet([]string{"name"}, []string{"replica"})
+	})
+
+	ctx, cancel := context.WithTimeout(WaitForGTIDs(context.Background(), testNewGTIDs), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name").Scan(&name); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the wait was not interrupted, took %v", elapsed)
+	}
+}
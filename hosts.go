@@ -0,0 +1,177 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostPolicy is the order in which the hosts of a multi-host DSN are tried,
+// see the DSN parameter hostPolicy.
+type hostPolicy uint8
+
+const (
+	hostSequential hostPolicy = iota // try the hosts in the order of the DSN
+	hostRandom                       // try the hosts in random order
+	hostRoundRobin                   // start with the next host for each connection
+)
+
+// Hosts are skipped for this period after a failure by default
+const defaultHostBackoff = 30 * time.Second
+
+// hostList keeps track of the hosts of a multi-host DSN which are down.
+// It is shared by all connections of a Connector.
+type hostList struct {
+	mu      sync.Mutex
+	addrs   []string
+	policy  hostPolicy
+	backoff time.Duration
+	down    map[string]time.Time // host => end of the backoff period
+	next    int                  // first host of the next round-robin connection
+}
+
+func newHostList(cfg *config) *hostList {
+	backoff := cfg.hostBackoff
+	if backoff == 0 {
+		backoff = defaultHostBackoff
+	}
+	return &hostList{
+		addrs:   cfg.addrs,
+		policy:  cfg.hostPolicy,
+		backoff: backoff,
+		down:    make(map[string]time.Time),
+	}
+}
+
+// order returns the hosts in the order in which they should be tried.
+// Hosts which are marked down are moved to the end, so they are only tried
+// when no other host is available.
+func (h *hostList) order() []string {
+	up, down := h.split()
+	return append(up, down...)
+}
+
+// available returns the hosts which are not marked down in the order in
+// which they should be tried.
+func (h *hostList) available() []string {
+	up, _ := h.split()
+	return up
+}
+
+// split returns the hosts in the order of the policy, separated into hosts
+// which are up and hosts which are marked down.
+func (h *hostList) split() (up, down []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := len(h.addrs)
+	if n == 0 {
+		return nil, nil
+	}
+	addrs := make([]string, n)
+	switch h.policy {
+	case hostRandom:
+		for i, j := range rand.Perm(n) {
+			addrs[i] = h.addrs[j]
+		}
+	case hostRoundRobin:
+		for i := range addrs {
+			addrs[i] = h.addrs[(h.next+i)%n]
+		}
+		h.next = (h.next + 1) % n
+	default:
+		copy(addrs, h.addrs)
+	}
+
+	now := time.Now()
+	up = make([]string, 0, n)
+	for _, addr := range addrs {
+		if until, ok := h.down[addr]; ok {
+			if now.Before(until) {
+				down = append(down, addr)
+				continue
+			}
+			delete(h.down, addr)
+		}
+		up = append(up, addr)
+	}
+	return up, down
+}
+
+// setAddrs replaces the hosts, e.g. after the topology of a cluster changed.
+func (h *hostList) setAddrs(addrs []string) {
+	h.mu.Lock()
+	h.addrs = addrs
+	if h.next >= len(addrs) {
+		h.next = 0
+	}
+	h.mu.Unlock()
+}
+
+// markDown skips addr for the backoff period.
+func (h *hostList) markDown(addr string) {
+	h.mu.Lock()
+	h.down[addr] = time.Now().Add(h.backoff)
+	h.mu.Unlock()
+}
+
+// markUp clears the backoff period of addr after a successful connection.
+func (h *hostList) markUp(addr string) {
+	h.mu.Lock()
+	delete(h.down, addr)
+	h.mu.Unlock()
+}
+
+// isHostDown reports whether err indicates that the host is unreachable or
+// is not the primary, i.e. whether the next host should be tried.
+func isHostDown(err error) bool {
+	if me, ok := err.(*MySQLError); ok {
+		return isReadOnlyMode(me)
+	}
+	return true
+}
+
+// isReadOnlyMode reports whether me is caused by a server running in
+// read-only mode. Error 1290 is also returned for other options, e.g.
+// --secure-file-priv, which only reject the statement.
+func isReadOnlyMode(me *MySQLError) bool {
+	switch me.Number {
+	case ER_READ_ONLY_MODE:
+		return true
+	case ER_OPTION_PREVENTS_STATEMENT:
+		return strings.Contains(me.Message, "--read-only") ||
+			strings.Contains(me.Message, "--super-read-only")
+	}
+	return false
+}
+
+// markHostDown marks the host of the connection down after a connection
+// loss or a read-only error, if it belongs to a multi-host DSN.
+func (mc *mysqlConn) markHostDown() {
+	if mc.hosts != nil && mc.cfg != nil {
+		mc.hosts.markDown(mc.cfg.addr)
+	}
+}
+
+// checkWritable returns errReadOnly if the server is read-only.
+// super_read_only implies read_only, so checking read_only is sufficient and
+// also works with MariaDB, which has no super_read_only.
+func (mc *mysqlConn) checkWritable() error {
+	readOnly, err := mc.getSystemVar("read_only")
+	if err != nil {
+		return err
+	}
+	if v, _ := readBool(string(readOnly)); v || string(readOnly) == "ON" {
+		return errReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostListOrder(t *testing.T) {
+	addrs := []string{"db1", "db2", "db3"}
+
+	h := newHostList(&config{addrs: addrs})
+	if h.backoff != defaultHostBackoff {
+		t.Errorf("expected default backoff, got %v", h.backoff)
+	}
+	for i := 0; i < 2; i++ {
+		if order := h.order(); !reflect.DeepEqual(order, addrs) {
+			t.Errorf("sequential: expected %v, got %v", addrs, order)
+		}
+	}
+
+	h.markDown("db1")
+	if order, want := h.order(), []string{"db2", "db3", "db1"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("db1 down: expected %v, got %v", want, order)
+	}
+	h.markUp("db1")
+	if order := h.order(); !reflect.DeepEqual(order, addrs) {
+		t.Errorf("db1 up: expected %v, got %v", addrs, order)
+	}
+
+	// the backoff period expires
+	h.backoff = time.Millisecond
+	h.markDown("db1")
+	time.Sleep(5 * time.Millisecond)
+	if order := h.order(); !reflect.DeepEqual(order, addrs) {
+		t.Errorf("backoff expired: expected %v, got %v", addrs, order)
+	}
+
+	h = newHostList(&config{addrs: addrs, hostPolicy: hostRoundRobin})
+	for i, want := range [][]string{
+		{"db1", "db2", "db3"},
+		{"db2", "db3", "db1"},
+		{"db3", "db1", "db2"},
+		{"db1", "db2", "db3"},
+	} {
+		if order := h.order(); !reflect.DeepEqual(order, want) {
+			t.Errorf("round-robin %d: expected %v, got %v", i, want, order)
+		}
+	}
+
+	h = newHostList(&config{addrs: addrs, hostPolicy: hostRandom})
+	order := h.order()
+	sort.Strings(order)
+	if !reflect.DeepEqual(order, addrs) {
+		t.Errorf("random: expected a permutation of %v, got %v", addrs, order)
+	}
+}
+
+// fakeHosts returns a dial function for the hosts of a multi-host DSN.
+// Hosts missing in readOnly are unreachable.
+func fakeHosts(readOnly map[string]bool, dialed *[]string) DialContextFunc {
+	var mu sync.Mutex
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		mu.Lock()
+		*dialed = append(*dialed, addr)
+		mu.Unlock()
+
+		ro, ok := readOnly[addr]
+		if !ok {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		return fakeDialer(func(query string) [][]byte {
+			switch query {
+			case "SELECT @@read_only":
+				if ro {
+					return fakeResultSet([]string{"@@read_only"}, []string{"1"})
+				}
+				return fakeResultSet([]string{"@@read_only"}, []string{"0"})
+			case "SELECT * FROM test INTO OUTFILE '/tmp/test'":
+				return [][]byte{append([]byte{iERR, 0x0a, 0x05, '#', 'H', 'Y', '0', '0', '0'},
+					"The MySQL server is running with the --secure-file-priv option so it cannot execute this statement"...)}
+			case "INSERT INTO test VALUES (1)":
+				if ro {
+					return [][]byte{append([]byte{iERR, 0x0a, 0x05, '#', 'H', 'Y', '0', '0', '0'},
+						"The MySQL server is running with the --read-only option so it cannot execute this statement"...)}
+				}
+			}
+			return nil
+		})(ctx, addr)
+	}
+}
+
+func TestConnectorFailover(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db1:3306,db2:3306,db3:3306)/dbname?requireWritable=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dialed []string
+	connector.Dialer = fakeHosts(map[string]bool{"db2:3306": true, "db3:3306": false}, &dialed)
+
+	conn, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if addr := conn.(*mysqlConn).cfg.addr; addr != "db3:3306" {
+		t.Errorf("expected connection to db3:3306, got %s", addr)
+	}
+	if want := []string{"db1:3306", "db2:3306", "db3:3306"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("expected dials %v, got %v", want, dialed)
+	}
+
+	// the failed hosts are tried last
+	if order, want := connector.hosts.order(), []string{"db3:3306", "db1:3306", "db2:3306"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("expected order %v, got %v", want, order)
+	}
+
+	// without requireWritable the first reachable host is used
+	connector, err = NewConnector("user@tcp(db1:3306,db2:3306,db3:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialed = nil
+	connector.Dialer = fakeHosts(map[string]bool{"db2:3306": true, "db3:3306": false}, &dialed)
+	conn2, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	if addr := conn2.(*mysqlConn).cfg.addr; addr != "db2:3306" {
+		t.Errorf("expected connection to db2:3306, got %s", addr)
+	}
+}
+
+func TestConnectorFailoverAllDown(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db1:3306,db2:3306)/dbname?requireWritable=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dialed []string
+	connector.Dialer = fakeHosts(map[string]bool{"db2:3306": true}, &dialed)
+
+	if _, err = connector.Connect(context.Background()); err != errReadOnly {
+		t.Errorf("expected errReadOnly, got %v", err)
+	}
+
+	// hosts which are down are still tried as last resort
+	dialed = nil
+	connector.Connect(context.Background())
+	if len(dialed) != 2 {
+		t.Errorf("expected 2 dials, got %v", dialed)
+	}
+}
+
+func TestReadOnlyErrorMarksHostDown(t *testing.T) {
+	connector, err := NewConnector("user@tcp(db1:3306,db2:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dialed []string
+	connector.Dialer = fakeHosts(map[string]bool{"db1:3306": true, "db2:3306": false}, &dialed)
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxIdleConns(1)
+
+	// db1 was demoted to a replica
+	_, err = db.Exec("INSERT INTO test VALUES (1)")
+	if !IsReadOnly(err) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+
+	// the connection to db1 is discarded and db2 is used instead
+	if _, err = db.Exec("INSERT INTO test VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"db1:3306", "db2:3306"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("expected dials %v, got %v", want, dialed)
+	}
+
+	// other options only reject the statement
+	_, err = db.Exec("SELECT * FROM test INTO OUTFILE '/tmp/test'")
+	if !hasErrorNumber(err, ER_OPTION_PREVENTS_STATEMENT) {
+		t.Fatalf("expected error 1290, got %v", err)
+	}
+	if _, err = db.Exec("INSERT INTO test VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dialed) != 2 {
+		t.Errorf("expected the connection to db2 to be kept, got dials %v", dialed)
+	}
+}
@@ -0,0 +1,199 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Replication lag is checked every second by default
+const defaultLagCheckInterval = time.Second
+
+// Starts the lag checks of the replicas if MaxReplicaLag is set. Replicas
+// are stale until their lag was checked.
+func (c *ReplicationConnector) startLagChecks() {
+	c.lagOnce.Do(func() {
+		if c.MaxReplicaLag <= 0 || len(c.Replicas) == 0 {
+			return
+		}
+
+		c.lagMu.Lock()
+		c.stale = make(map[string]bool, len(c.Replicas))
+		for i := range c.Replicas {
+			c.stale[strconv.Itoa(i)] = true
+		}
+		c.lagMu.Unlock()
+
+		// Each replica is checked on its own, so that a replica which hangs
+		// doesn't delay the checks of the others
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stopLagChecks = cancel
+		for i := range c.Replicas {
+			c.lagChecks.Add(1)
+			go c.checkLag(ctx, i)
+		}
+	})
+}
+
+// checkLag samples the lag of replica i over a dedicated connection until
+// ctx is canceled.
+func (c *ReplicationConnector) checkLag(ctx context.Context, i int) {
+	defer c.lagChecks.Done()
+
+	interval := c.LagCheckInterval
+	if interval <= 0 {
+		interval = defaultLagCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var mc *mysqlConn
+	defer func() {
+		if mc != nil {
+			mc.Close()
+		}
+	}()
+
+	for {
+		mc = c.sampleLag(ctx, i, mc, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleLag checks the lag of replica i over mc, which is (re)connected if
+// necessary, and returns the connection for the next check.
+func (c *ReplicationConnector) sampleLag(ctx context.Context, i int, mc *mysqlConn, timeout time.Duration) *mysqlConn {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if mc != nil && !mc.IsValid() {
+		mc.Close()
+		mc = nil
+	}
+	if mc == nil {
+		conn, err := c.Replicas[i].Connect(ctx)
+		if err != nil {
+			c.setStale(i, true)
+			return nil
+		}
+		mc = conn.(*mysqlConn)
+	}
+
+	lag, err := mc.replicaLag(ctx, c.LagQuery)
+	if err != nil && !c.isStale(strconv.Itoa(i)) {
+		errLog.Print(err)
+	}
+	c.setStale(i, err != nil || lag > c.MaxReplicaLag)
+	return mc
+}
+
+func (c *ReplicationConnector) setStale(i int, stale bool) {
+	c.lagMu.Lock()
+	c.stale[strconv.Itoa(i)] = stale
+	c.lagMu.Unlock()
+}
+
+// isStale reports whether the replica lags behind by more than
+// MaxReplicaLag, or its lag is unknown.
+func (c *ReplicationConnector) isStale(key string) bool {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	return c.stale[key]
+}
+
+// Close stops the lag checks. It is called by sql.DB.Close.
+func (c *ReplicationConnector) Close() error {
+	c.lagOnce.Do(func() {})
+	if c.stopLagChecks != nil {
+		c.stopLagChecks()
+		c.lagChecks.Wait()
+	}
+	return nil
+}
+
+// replicaLag returns the replication lag of the server. If query is empty,
+// Seconds_Behind_Source of SHOW REPLICA STATUS is used, otherwise query must
+// return the lag in seconds, e.g. computed from a heartbeat table.
+func (mc *mysqlConn) replicaLag(ctx context.Context, query string) (time.Duration, error) {
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clearDeadline()
+
+	if query != "" {
+		value, err := mc.queryValue(query)
+		if err != nil {
+			return 0, err
+		}
+		seconds, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	rows, err := mc.Query("SHOW REPLICA STATUS", nil)
+	if hasErrorNumber(err, ER_PARSE_ERROR) {
+		// MySQL before 8.0.22 and MariaDB before 10.5.1
+		rows, err = mc.Query("SHOW SLAVE STATUS", nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	column := -1
+	for i, name := range rows.Columns() {
+		if name == "Seconds_Behind_Source" || name == "Seconds_Behind_Master" {
+			column = i
+		}
+	}
+	if column < 0 {
+		return 0, errNoReplica
+	}
+
+	// With multi-source replication, the lag of the slowest channel counts
+	var lag time.Duration
+	var found bool
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err = rows.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		value, ok := dest[column].([]byte)
+		if !ok {
+			return 0, errReplStopped
+		}
+		seconds, err := strconv.Atoi(string(value))
+		if err != nil {
+			return 0, err
+		}
+		if d := time.Duration(seconds) * time.Second; d > lag {
+			lag = d
+		}
+		found = true
+	}
+	if !found {
+		return 0, errNoReplica
+	}
+	return lag, nil
+}
@@ -0,0 +1,136 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var parseErrorPayload = append([]byte{iERR, 0x28, 0x04, '#', '4', '2', '0', '0', '0'},
+	"You have an error in your SQL syntax"...)
+
+func TestReplicaLag(t *testing.T) {
+	status := func(column string, lags ...string) [][]byte {
+		rows := make([][]string, len(lags))
+		for i, lag := range lags {
+			rows[i] = []string{"Waiting for source to send event", lag}
+		}
+		return fakeResultSet([]string{"Replica_IO_State", column}, rows...)
+	}
+
+	for _, tst := range []struct {
+		name    string
+		query   string
+		handler fakeHandler
+		lag     time.Duration
+		err     error
+	}{
+		{"replica status", "", func(query string) [][]byte {
+			return status("Seconds_Behind_Source", "3")
+		}, 3 * time.Second, nil},
+		{"multi-source", "", func(query string) [][]byte {
+			return status("Seconds_Behind_Source", "3", "7", "0")
+		}, 7 * time.Second, nil},
+		{"slave status", "", func(query string) [][]byte {
+			if query == "SHOW REPLICA STATUS" {
+				return [][]byte{parseErrorPayload}
+			}
+			return status("Seconds_Behind_Master", "2")
+		}, 2 * time.Second, nil},
+		{"stopped", "", func(query string) [][]byte {
+			return status("Seconds_Behind_Source", "")
+		}, 0, errReplStopped},
+		{"no replica", "", func(query string) [][]byte {
+			return status("Seconds_Behind_Source")
+		}, 0, errNoReplica},
+		{"heartbeat", "SELECT lag FROM heartbeat", func(query string) [][]byte {
+			return fakeResultSet([]string{"lag"}, []string{"0.250000"})
+		}, 250 * time.Millisecond, nil},
+	} {
+		connector, err := NewConnector("user@tcp(replica:3306)/dbname")
+		if err != nil {
+			t.Fatal(err)
+		}
+		connector.Dialer = fakeDialer(tst.handler)
+		conn, err := connector.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lag, err := conn.(*mysqlConn).replicaLag(context.Background(), tst.query)
+		if err != tst.err {
+			t.Errorf("%s: expected error %v, got %v", tst.name, tst.err, err)
+		}
+		if lag != tst.lag {
+			t.Errorf("%s: expected lag %v, got %v", tst.name, tst.lag, lag)
+		}
+		conn.Close()
+	}
+}
+
+func TestReplicationConnectorLag(t *testing.T) {
+	var lag int32 = 1
+	connector, queries := fakeReplication(t, &lag)
+	connector.MaxReplicaLag = 5 * time.Second
+	connector.LagCheckInterval = 5 * time.Millisecond
+	var dials int32
+	dial := connector.Replicas[0].Dialer
+	connector.Replicas[0].Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return dial(ctx, addr)
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			var name string
+			if err := db.QueryRow("SELECT name").Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			if name == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected reads from %s, got %s", want, name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor("replica")
+
+	atomic.StoreInt32(&lag, 11)
+	waitFor("primary")
+
+	// caught up again
+	atomic.StoreInt32(&lag, 1)
+	waitFor("replica")
+
+	// the connections of the lag checks and of the reads were kept
+	if n := atomic.LoadInt32(&dials); n != 2 {
+		t.Errorf("expected 2 dials of the replica, got %d", n)
+	}
+
+	db.Close()
+	checks := len(queries())
+	time.Sleep(20 * time.Millisecond)
+	if len(queries()) != checks {
+		t.Error("expected the lag checks to be stopped")
+	}
+}
@@ -30,6 +30,7 @@ func (mc *mysqlConn) readPacket() ([]byte, error) {
 		data, err := mc.buf.readNext(4)
 		if err != nil {
 			errLog.Print(err)
+			mc.markHostDown()
 			mc.Close()
 			return nil, driver.ErrBadConn
 		}
@@ -57,6 +58,7 @@ func (mc *mysqlConn) readPacket() ([]byte, error) {
 		data, err = mc.buf.readNext(pktLen)
 		if err != nil {
 			errLog.Print(err)
+			mc.markHostDown()
 			mc.Close()
 			return nil, driver.ErrBadConn
 		}
@@ -76,6 +78,17 @@ func (mc *mysqlConn) readPacket() ([]byte, error) {
 	}
 }
 
+// Closes the network connection after a malformed packet was received,
+// since the connection is out of sync with the server afterwards
+func (mc *mysqlConn) malformedPacket() error {
+	errLog.Print(errMalformPkt)
+	if mc.netConn != nil {
+		mc.netConn.Close()
+		mc.netConn = nil
+	}
+	return errMalformPkt
+}
+
 // Write packet buffer 'data'
 func (mc *mysqlConn) writePacket(data []byte) error {
 	pktLen := len(data) - 4
@@ -117,6 +130,7 @@ func (mc *mysqlConn) writePacket(data []byte) error {
 		} else {
 			errLog.Print(err)
 		}
+		mc.markHostDown()
 		return driver.ErrBadConn
 	}
 }
@@ -127,6 +141,7 @@ func (mc *mysqlConn) writePacket(data []byte) error {
 
 // Handshake Initialization Packet
 // http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::Handshake
+// The returned cipher may point into the read buffer.
 func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 	data, err := mc.readPacket()
 	if err != nil {
@@ -147,16 +162,28 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 	}
 
 	// server version [null terminated string]
-	// connection id [4 bytes]
-	pos := 1 + bytes.IndexByte(data[1:], 0x00) + 1 + 4
+	end := bytes.IndexByte(data[1:], 0x00)
+	if end < 0 {
+		return nil, errMalformPkt
+	}
+	info := ServerInfo{Version: string(data[1 : 1+end])}
+	pos := 1 + end + 1
 
+	// connection id [4 bytes]
 	// first part of the password cipher [8 bytes]
-	cipher := data[pos : pos+8]
-
 	// (filler) always 0x00 [1 byte]
+	// capability flags (lower 2 bytes) [2 bytes]
+	if len(data) < pos+4+8+1+2 {
+		return nil, errMalformPkt
+	}
+	info.ConnectionID = binary.LittleEndian.Uint32(data[pos : pos+4])
+	pos += 4
+
+	// the capacity is limited, so that appending the second part of the
+	// password cipher doesn't overwrite the packet
+	cipher := data[pos : pos+8 : pos+8]
 	pos += 8 + 1
 
-	// capability flags (lower 2 bytes) [2 bytes]
 	mc.flags = clientFlag(binary.LittleEndian.Uint16(data[pos : pos+2]))
 	if mc.flags&clientProtocol41 == 0 {
 		return nil, errOldProtocol
@@ -171,8 +198,24 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 		// status flags [2 bytes]
 		// capability flags (upper 2 bytes) [2 bytes]
 		// length of auth-plugin-data [1 byte]
-		// reserved (all [00]) [10 bytes]
-		pos += 1 + 2 + 2 + 1 + 10
+		// reserved (all [00]) [6 bytes]
+		// MariaDB extended capabilities or reserved [4 bytes]
+		if len(data) < pos+1+2+2+1+10 {
+			return nil, errMalformPkt
+		}
+		info.Collation = data[pos]
+		info.Status = StatusFlag(binary.LittleEndian.Uint16(data[pos+1 : pos+3]))
+		pos += 1 + 2
+
+		mc.flags |= clientFlag(binary.LittleEndian.Uint16(data[pos:pos+2])) << 16
+		pos += 2 + 1 + 6
+
+		// MariaDB 10.2+ clears CLIENT_MYSQL (CLIENT_LONG_PASSWORD) to
+		// announce its extended capabilities
+		if mc.flags&clientLongPassword == 0 {
+			info.MariaDBCapabilities = binary.LittleEndian.Uint32(data[pos : pos+4])
+		}
+		pos += 4
 
 		// second part of the password cipher [mininum 13 bytes],
 		// where len=MAX(13, length of auth-plugin-data - 8)
@@ -186,23 +229,28 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 		//
 		// The official Python library uses the fixed length 12
 		// which seems to work but technically could have a hidden bug.
+		if len(data) < pos+13 || data[pos+12] != 0x00 {
+			return nil, errMalformPkt
+		}
 		cipher = append(cipher, data[pos:pos+12]...)
-
-		// TODO: Verify string termination
-		// EOF if version (>= 5.5.7 and < 5.5.10) or (>= 5.6.0 and < 5.6.2)
-		// \NUL otherwise
-		//
-		//if data[len(data)-1] == 0 {
-		//	return
-		//}
-		//return errMalformPkt
-		return cipher, nil
+		pos += 13
+
+		// auth-plugin name [null terminated string]
+		// EOF terminated if version (>= 5.5.7 and < 5.5.10) or
+		// (>= 5.6.0 and < 5.6.2)
+		if mc.flags&clientPluginAuth != 0 {
+			if end = bytes.IndexByte(data[pos:], 0x00); end < 0 {
+				end = len(data) - pos
+			}
+			info.AuthPlugin = string(data[pos : pos+end])
+		}
 	}
 
-	// make a memory safe copy of the cipher slice
-	var b [8]byte
-	copy(b[:], cipher)
-	return b[:], nil
+	info.Capabilities = uint32(mc.flags)
+	info.Flavor = detectFlavor(info.Version, info.MariaDBCapabilities)
+	info.Version = trimMariaDBPrefix(info.Version)
+	mc.serverInfo = info
+	return cipher, nil
 }
 
 // Client Authentication Packet
@@ -214,7 +262,11 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 		clientLongPassword |
 		clientTransactions |
 		clientLocalFiles |
-		mc.flags&clientLongFlag
+		mc.flags&clientLongFlag |
+		mc.flags&clientSessionTrack |
+		mc.flags&clientDeprecateEOF |
+		mc.flags&clientQueryAttributes |
+		mc.flags&clientPluginAuth
 
 	if mc.cfg.clientFoundRows {
 		clientFlags |= clientFoundRows
@@ -236,6 +288,11 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 		pktLen += n + 1
 	}
 
+	// To name the auth plugin of the scramble
+	if clientFlags&clientPluginAuth != 0 {
+		pktLen += len(authNativePassword) + 1
+	}
+
 	// Calculate packet length and get buffer with that size
 	data := mc.buf.takeSmallBuffer(pktLen + 4)
 	if data == nil {
@@ -294,30 +351,72 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 	if len(mc.cfg.dbname) > 0 {
 		pos += copy(data[pos:], mc.cfg.dbname)
 		data[pos] = 0x00
+		pos++
+	}
+
+	// Auth plugin name [null terminated string]
+	if clientFlags&clientPluginAuth != 0 {
+		pos += copy(data[pos:], authNativePassword)
+		data[pos] = 0x00
 	}
 
+	// From now on the flags are the negotiated capabilities
+	mc.flags &= clientFlags
+
 	// Send Auth packet
 	return mc.writePacket(data)
 }
 
-//  Client old authentication packet
-// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::AuthSwitchResponse
-func (mc *mysqlConn) writeOldAuthPacket(cipher []byte) error {
-	// User password
-	scrambleBuff := scrambleOldPassword(cipher, []byte(mc.cfg.passwd))
+// Change User Packet (COM_CHANGE_USER)
+// Authenticates the user of the config again on the open connection, which
+// resets the session.
+// http://dev.mysql.com/doc/internals/en/com-change-user.html
+func (mc *mysqlConn) writeChangeUserPacket(cipher []byte) error {
+	// Reset Packet Sequence
+	mc.sequence = 0
+
+	// User Password
+	scrambleBuff := scramblePassword(cipher, []byte(mc.cfg.passwd))
 
-	// Calculate the packet lenght and add a tailing 0
-	pktLen := len(scrambleBuff) + 1
-	data := mc.buf.takeSmallBuffer(4 + pktLen)
+	pktLen := 1 + len(mc.cfg.user) + 1 + 1 + len(scrambleBuff) + len(mc.cfg.dbname) + 1 + 2
+	if mc.flags&clientPluginAuth != 0 {
+		pktLen += len(authNativePassword) + 1
+	}
+	data := mc.buf.takeSmallBuffer(pktLen + 4)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
 		errLog.Print(errBusyBuffer)
 		return driver.ErrBadConn
 	}
 
-	// Add the scrambled password [null terminated string]
-	copy(data[4:], scrambleBuff)
-	data[4+pktLen-1] = 0x00
+	// Add command byte
+	data[4] = comChangeUser
+	pos := 5
+
+	// User [null terminated string]
+	pos += copy(data[pos:], mc.cfg.user)
+	data[pos] = 0x00
+	pos++
+
+	// ScrambleBuffer [length encoded integer]
+	data[pos] = byte(len(scrambleBuff))
+	pos += 1 + copy(data[pos+1:], scrambleBuff)
+
+	// Databasename [null terminated string]
+	pos += copy(data[pos:], mc.cfg.dbname)
+	data[pos] = 0x00
+	pos++
+
+	// Charset [2 bytes]
+	data[pos] = collation_utf8_general_ci
+	data[pos+1] = 0x00
+	pos += 2
+
+	// Auth plugin name [null terminated string]
+	if mc.flags&clientPluginAuth != 0 {
+		pos += copy(data[pos:], authNativePassword)
+		data[pos] = 0x00
+	}
 
 	return mc.writePacket(data)
 }
@@ -348,7 +447,16 @@ func (mc *mysqlConn) writeCommandPacketStr(command byte, arg string) error {
 	// Reset Packet Sequence
 	mc.sequence = 0
 
-	pktLen := 1 + len(arg)
+	// Query attributes must be sent with each query, if negotiated
+	var attrs []byte
+	if command == comQuery && mc.flags&clientQueryAttributes != 0 {
+		var err error
+		if attrs, err = mc.appendQueryAttributes(nil); err != nil {
+			return err
+		}
+	}
+
+	pktLen := 1 + len(attrs) + len(arg)
 	data := mc.buf.takeBuffer(pktLen + 4)
 	if data == nil {
 		// can not take the buffer. Something must be wrong with the connection
@@ -359,8 +467,11 @@ func (mc *mysqlConn) writeCommandPacketStr(command byte, arg string) error {
 	// Add command byte
 	data[4] = command
 
+	// Add query attributes
+	copy(data[5:], attrs)
+
 	// Add arg
-	copy(data[5:], arg)
+	copy(data[5+len(attrs):], arg)
 
 	// Send CMD packet
 	return mc.writePacket(data)
@@ -438,7 +549,7 @@ func (mc *mysqlConn) readResultSetHeaderPacket() (int, error) {
 			return int(num), nil
 		}
 
-		return 0, errMalformPkt
+		return 0, mc.malformedPacket()
 	}
 	return 0, err
 }
@@ -447,54 +558,133 @@ func (mc *mysqlConn) readResultSetHeaderPacket() (int, error) {
 // http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-ERR_Packet
 func (mc *mysqlConn) handleErrorPacket(data []byte) error {
 	if data[0] != iERR {
-		return errMalformPkt
+		return mc.malformedPacket()
 	}
 
 	// 0xff [1 byte]
 
+	if len(data) < 3 {
+		return mc.malformedPacket()
+	}
+
 	// Error Number [16 bit uint]
-	errno := binary.LittleEndian.Uint16(data[1:3])
+	me := &MySQLError{
+		Number: binary.LittleEndian.Uint16(data[1:3]),
+	}
 
 	pos := 3
 
 	// SQL State [optional: # + 5bytes string]
-	if data[3] == 0x23 {
-		//sqlstate := string(data[4 : 4+5])
+	if len(data) >= 9 && data[3] == 0x23 {
+		copy(me.SQLState[:], data[4:4+5])
 		pos = 9
 	}
 
 	// Error Message [string]
-	return &MySQLError{
-		Number:  errno,
-		Message: string(data[pos:]),
+	me.Message = string(data[pos:])
+
+	// The server is no longer the primary of a multi-host DSN, so the
+	// connection is discarded and new connections try the other hosts
+	if mc.hosts != nil && isReadOnlyMode(me) {
+		mc.markHostDown()
+		mc.netConn.Close()
+		mc.netConn = nil
 	}
+	return me
 }
 
 // Ok Packet
 // http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-OK_Packet
 func (mc *mysqlConn) handleOkPacket(data []byte) error {
+	if err := mc.readOkPacket(data); err != nil {
+		return err
+	}
+	return mc.handleWarnings()
+}
+
+// Reads the status of an OK packet, which may also terminate a result set
+// with the header 0xfe
+func (mc *mysqlConn) readOkPacket(data []byte) error {
 	var n, m int
 
 	// 0x00 [1 byte]
 
 	// Affected rows [Length Coded Binary]
 	mc.affectedRows, _, n = readLengthEncodedInteger(data[1:])
+	if 1+n > len(data) {
+		return mc.malformedPacket()
+	}
 
 	// Insert id [Length Coded Binary]
 	mc.insertId, _, m = readLengthEncodedInteger(data[1+n:])
 
-	// server_status [2 bytes]
+	pos := 1 + n + m
+	if pos > len(data) {
+		return mc.malformedPacket()
+	}
+	if len(data) >= pos+4 {
+		// server_status [2 bytes]
+		mc.status = statusFlag(binary.LittleEndian.Uint16(data[pos : pos+2]))
+
+		// warning count [2 bytes]
+		mc.warningCount = binary.LittleEndian.Uint16(data[pos+2 : pos+4])
+		pos += 4
+
+		if mc.flags&clientSessionTrack == 0 {
+			// info [string]
+			mc.info = string(data[pos:])
+		} else if len(data) > pos {
+			// info [length encoded string]
+			info, _, n, err := readLengthEncodedString(data[pos:])
+			if err != nil {
+				return mc.malformedPacket()
+			}
+			mc.info = string(info)
+			pos += n
+
+			// session state info [length encoded string]
+			if mc.status&statusSessionStateChanged != 0 && len(data) > pos {
+				if err = mc.handleSessionState(data[pos:]); err != nil {
+					return mc.malformedPacket()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EOF Packet
+// Checks whether data is the packet terminating a result set or a list of
+// columns and reads its status. With CLIENT_DEPRECATE_EOF result sets are
+// terminated by an OK packet with the header 0xfe instead.
+// http://dev.mysql.com/doc/internals/en/packet-EOF_Packet.html
+func (mc *mysqlConn) readEOFPacket(data []byte) (bool, error) {
+	if data[0] != iEOF {
+		return false, nil
+	}
 
-	// warning count [2 bytes]
-	if !mc.strict {
-		return nil
-	} else {
-		pos := 1 + n + m + 2
-		if binary.LittleEndian.Uint16(data[pos:pos+2]) > 0 {
-			return mc.getWarnings()
+	if mc.flags&clientDeprecateEOF == 0 {
+		// A row starting with a length encoded string of 8 bytes length is
+		// never shorter than 9 bytes
+		if len(data) >= 9 {
+			return false, nil
 		}
-		return nil
+
+		if len(data) >= 5 {
+			// warning count [2 bytes]
+			mc.warningCount = binary.LittleEndian.Uint16(data[1:3])
+
+			// status flags [2 bytes]
+			mc.status = statusFlag(binary.LittleEndian.Uint16(data[3:5]))
+		}
+		return true, nil
+	}
+
+	// Such a row is longer than a single packet
+	if len(data) >= maxPacketSize {
+		return false, nil
 	}
+	return true, mc.readOkPacket(data)
 }
 
 // Read Packets as Field Packets until EOF-Packet or an Error appears
@@ -503,50 +693,60 @@ func (mc *mysqlConn) readColumns(count int) ([]mysqlField, error) {
 	columns := make([]mysqlField, count)
 
 	for i := 0; ; i++ {
+		// No EOF Packet with CLIENT_DEPRECATE_EOF
+		if i == count && mc.flags&clientDeprecateEOF != 0 {
+			return columns, nil
+		}
+
 		data, err := mc.readPacket()
 		if err != nil {
 			return nil, err
 		}
 
 		// EOF Packet
-		if data[0] == iEOF && (len(data) == 5 || len(data) == 1) {
+		if eof, _ := mc.readEOFPacket(data); eof {
 			if i == count {
 				return columns, nil
 			}
 			return nil, fmt.Errorf("ColumnsCount mismatch n:%d len:%d", count, len(columns))
 		}
 
+		// More columns than announced
+		if i == count {
+			return nil, mc.malformedPacket()
+		}
+
 		// Catalog
 		pos, err := skipLengthEncodedString(data)
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
 		}
 
 		// Database [len coded string]
 		n, err := skipLengthEncodedString(data[pos:])
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
 		}
 		pos += n
 
 		// Table [len coded string]
 		n, err = skipLengthEncodedString(data[pos:])
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
 		}
 		pos += n
 
 		// Original table [len coded string]
 		n, err = skipLengthEncodedString(data[pos:])
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
 		}
 		pos += n
 
 		// Name [len coded string]
 		name, _, n, err := readLengthEncodedString(data[pos:])
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
 		}
 		columns[i].name = string(name)
 		pos += n
@@ -554,13 +754,24 @@ func (mc *mysqlConn) readColumns(count int) ([]mysqlField, error) {
 		// Original name [len coded string]
 		n, err = skipLengthEncodedString(data[pos:])
 		if err != nil {
-			return nil, err
+			return nil, mc.malformedPacket()
+		}
+
+		// Fixed length fields [11 bytes]
+		if len(data) < pos+n+11 {
+			return nil, mc.malformedPacket()
 		}
 
 		// Filler [1 byte]
+		pos += n + 1
+
 		// Charset [16 bit uint]
+		columns[i].charSet = binary.LittleEndian.Uint16(data[pos : pos+2])
+		pos += 2
+
 		// Length [32 bit uint]
-		pos += n + 1 + 2 + 4
+		columns[i].length = binary.LittleEndian.Uint32(data[pos : pos+4])
+		pos += 4
 
 		// Field type [byte]
 		columns[i].fieldType = data[pos]
@@ -568,11 +779,16 @@ func (mc *mysqlConn) readColumns(count int) ([]mysqlField, error) {
 
 		// Flags [16 bit uint]
 		columns[i].flags = fieldFlag(binary.LittleEndian.Uint16(data[pos : pos+2]))
-		//pos += 2
+		pos += 2
 
 		// Decimals [8 bit uint]
+		columns[i].decimals = data[pos]
 		//pos++
 
+		if typeDecoderRegister != nil {
+			columns[i].decoder = lookupTypeDecoder(&columns[i])
+		}
+
 		// Default value [len coded binary]
 		//if pos < len(data) {
 		//	defaultVal, _, err = bytesToLengthCodedBinary(data[pos:])
@@ -591,7 +807,11 @@ func (rows *textRows) readRow(dest []driver.Value) error {
 	}
 
 	// EOF Packet
-	if data[0] == iEOF && len(data) == 5 {
+	if eof, err := mc.readEOFPacket(data); eof {
+		rows.warningCount = mc.warningCount
+		if err != nil {
+			return err
+		}
 		return io.EOF
 	}
 
@@ -603,6 +823,9 @@ func (rows *textRows) readRow(dest []driver.Value) error {
 	for i := range dest {
 		// Read bytes and convert to string
 		dest[i], isNull, n, err = readLengthEncodedString(data[pos:])
+		if err != nil {
+			return mc.malformedPacket()
+		}
 		pos += n
 		if err == nil {
 			if !isNull {
@@ -612,10 +835,14 @@ func (rows *textRows) readRow(dest []driver.Value) error {
 					switch rows.columns[i].fieldType {
 					case fieldTypeTimestamp, fieldTypeDateTime,
 						fieldTypeDate, fieldTypeNewDate:
-						dest[i], err = parseDateTime(
-							string(dest[i].([]byte)),
-							mc.cfg.loc,
-						)
+						if isZeroDate(dest[i].([]byte)) {
+							dest[i], err = mc.cfg.zeroDate.value(mc.cfg.loc)
+						} else {
+							dest[i], err = parseDateTime(
+								string(dest[i].([]byte)),
+								mc.cfg.loc,
+							)
+						}
 						if err == nil {
 							continue
 						}
@@ -632,20 +859,37 @@ func (rows *textRows) readRow(dest []driver.Value) error {
 		return err // err != nil
 	}
 
-	return nil
+	return rows.applyDecoders(dest)
 }
 
-// Reads Packets until EOF-Packet or an Error appears. Returns count of Packets read
+// Reads Packets until EOF-Packet or an Error appears
 func (mc *mysqlConn) readUntilEOF() error {
 	for {
 		data, err := mc.readPacket()
+		if err != nil {
+			return err
+		}
 
-		// No Err and no EOF Packet
-		if err == nil && data[0] != iEOF {
-			continue
+		// No EOF Packet
+		if eof, err := mc.readEOFPacket(data); eof {
+			return err
+		}
+	}
+}
+
+// Skips the given count of Column Definition Packets and the following
+// EOF-Packet, which is omitted with CLIENT_DEPRECATE_EOF
+func (mc *mysqlConn) skipColumns(count int) error {
+	if mc.flags&clientDeprecateEOF == 0 {
+		return mc.readUntilEOF()
+	}
+
+	for i := 0; i < count; i++ {
+		if _, err := mc.readPacket(); err != nil {
+			return err
 		}
-		return err // Err or EOF
 	}
+	return nil
 }
 
 /******************************************************************************
@@ -662,6 +906,11 @@ func (stmt *mysqlStmt) readPrepareResultPacket() (uint16, error) {
 			return 0, stmt.mc.handleErrorPacket(data)
 		}
 
+		// statement id, column count and param count [8 bytes]
+		if len(data) < 9 {
+			return 0, stmt.mc.malformedPacket()
+		}
+
 		// statement id [4 bytes]
 		stmt.id = binary.LittleEndian.Uint32(data[1:5])
 
@@ -673,16 +922,12 @@ func (stmt *mysqlStmt) readPrepareResultPacket() (uint16, error) {
 
 		// Reserved [8 bit]
 
-		// Warning count [16 bit uint]
-		if !stmt.mc.strict {
-			return columnCount, nil
-		} else {
-			// Check for warnings count > 0, only available in MySQL > 4.1
-			if len(data) >= 12 && binary.LittleEndian.Uint16(data[10:12]) > 0 {
-				return columnCount, stmt.mc.getWarnings()
-			}
-			return columnCount, nil
+		// Warning count [16 bit uint], only available in MySQL > 4.1
+		stmt.mc.warningCount = 0
+		if len(data) >= 12 {
+			stmt.mc.warningCount = binary.LittleEndian.Uint16(data[10:12])
 		}
+		return columnCount, nil
 	}
 	return 0, err
 }
@@ -756,9 +1001,13 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 	// Reset packet-sequence
 	mc.sequence = 0
 
+	// Query attributes are sent as additional named parameters
+	attrs := mc.takeQueryAttributes()
+	withAttrs := mc.flags&clientQueryAttributes != 0
+
 	var data []byte
 
-	if len(args) == 0 {
+	if len(args) == 0 && len(attrs) == 0 {
 		data = mc.buf.takeBuffer(minPktLen)
 	} else {
 		data = mc.buf.takeCompleteBuffer()
@@ -780,6 +1029,9 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 
 	// flags (0: CURSOR_TYPE_NO_CURSOR) [1 byte]
 	data[9] = 0x00
+	if len(args) == 0 && len(attrs) > 0 {
+		data[9] = cursorParameterCountAvailable
+	}
 
 	// iteration_count (uint32(1)) [4 bytes]
 	data[10] = 0x01
@@ -787,11 +1039,21 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 	data[12] = 0x00
 	data[13] = 0x00
 
-	if len(args) > 0 {
+	if len(args) > 0 || len(attrs) > 0 {
 		pos := minPktLen
 
+		// type [2 bytes] and with query attributes also the empty name
+		// [1 byte] of each parameter
+		typeLen := 2
+		if withAttrs {
+			// parameter_count [length encoded integer]
+			pos = len(appendLengthEncodedInteger(data[:pos], uint64(len(args)+len(attrs))))
+			typeLen = 3
+		}
+		attrTypesLen := queryAttributesTypesLen(attrs)
+
 		var nullMask []byte
-		if maskLen, typesLen := (len(args)+7)/8, 1+2*len(args); pos+maskLen+typesLen >= len(data) {
+		if maskLen, typesLen := (len(args)+len(attrs)+7)/8, 1+typeLen*len(args)+attrTypesLen; pos+maskLen+typesLen >= len(data) {
 			// buffer has to be extended but we don't know by how much so
 			// we depend on append after all data with known sizes fit.
 			// We stop at that because we deal with a lot of columns here
@@ -813,28 +1075,35 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 		data[pos] = 0x01
 		pos++
 
-		// type of each parameter [len(args)*2 bytes]
+		// type of each parameter [len(args)*typeLen bytes]
+		// followed by the type and name of each query attribute
 		paramTypes := data[pos:]
-		pos += len(args) * 2
+		pos += len(args)*typeLen + attrTypesLen
 
 		// value of each parameter [n bytes]
 		paramValues := data[pos:pos]
 		valuesCap := cap(paramValues)
 
 		for i, arg := range args {
+			t := i * typeLen
+			if withAttrs {
+				// name [length encoded string], always empty
+				paramTypes[t+2] = 0x00
+			}
+
 			// build NULL-bitmap
 			if arg == nil {
 				nullMask[i/8] |= 1 << (uint(i) & 7)
-				paramTypes[i+i] = fieldTypeNULL
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeNULL
+				paramTypes[t+1] = 0x00
 				continue
 			}
 
 			// cache types and values
 			switch v := arg.(type) {
 			case int64:
-				paramTypes[i+i] = fieldTypeLongLong
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeLongLong
+				paramTypes[t+1] = 0x00
 
 				if cap(paramValues)-len(paramValues)-8 >= 0 {
 					paramValues = paramValues[:len(paramValues)+8]
@@ -848,9 +1117,25 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 					)
 				}
 
+			case uint64:
+				paramTypes[t] = fieldTypeLongLong
+				paramTypes[t+1] = 0x80 // type is unsigned
+
+				if cap(paramValues)-len(paramValues)-8 >= 0 {
+					paramValues = paramValues[:len(paramValues)+8]
+					binary.LittleEndian.PutUint64(
+						paramValues[len(paramValues)-8:],
+						v,
+					)
+				} else {
+					paramValues = append(paramValues,
+						uint64ToBytes(v)...,
+					)
+				}
+
 			case float64:
-				paramTypes[i+i] = fieldTypeDouble
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeDouble
+				paramTypes[t+1] = 0x00
 
 				if cap(paramValues)-len(paramValues)-8 >= 0 {
 					paramValues = paramValues[:len(paramValues)+8]
@@ -865,8 +1150,8 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 				}
 
 			case bool:
-				paramTypes[i+i] = fieldTypeTiny
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeTiny
+				paramTypes[t+1] = 0x00
 
 				if v {
 					paramValues = append(paramValues, 0x01)
@@ -877,8 +1162,8 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 			case []byte:
 				// Common case (non-nil value) first
 				if v != nil {
-					paramTypes[i+i] = fieldTypeString
-					paramTypes[i+i+1] = 0x00
+					paramTypes[t] = fieldTypeString
+					paramTypes[t+1] = 0x00
 
 					if len(v) < mc.maxPacketAllowed-pos-len(paramValues)-(len(args)-(i+1))*64 {
 						paramValues = appendLengthEncodedInteger(paramValues,
@@ -895,12 +1180,12 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 
 				// Handle []byte(nil) as a NULL value
 				nullMask[i/8] |= 1 << (uint(i) & 7)
-				paramTypes[i+i] = fieldTypeNULL
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeNULL
+				paramTypes[t+1] = 0x00
 
 			case string:
-				paramTypes[i+i] = fieldTypeString
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeString
+				paramTypes[t+1] = 0x00
 
 				if len(v) < mc.maxPacketAllowed-pos-len(paramValues)-(len(args)-(i+1))*64 {
 					paramValues = appendLengthEncodedInteger(paramValues,
@@ -913,13 +1198,34 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 					}
 				}
 
+			case Decimal:
+				paramTypes[t] = fieldTypeNewDecimal
+				paramTypes[t+1] = 0x00
+
+				val := v.String()
+				paramValues = appendLengthEncodedInteger(paramValues,
+					uint64(len(val)),
+				)
+				paramValues = append(paramValues, val...)
+
 			case time.Time:
-				paramTypes[i+i] = fieldTypeString
-				paramTypes[i+i+1] = 0x00
+				paramTypes[t] = fieldTypeString
+				paramTypes[t+1] = 0x00
 
 				var val []byte
 				if v.IsZero() {
-					val = []byte("0000-00-00")
+					switch mc.cfg.zeroDate {
+					case zeroDateNull:
+						nullMask[i/8] |= 1 << (uint(i) & 7)
+						paramTypes[t] = fieldTypeNULL
+						continue
+					case zeroDateError:
+						return errZeroTime
+					case zeroDateMin:
+						val = []byte(minDateTime)
+					default:
+						val = []byte("0000-00-00")
+					}
 				} else {
 					val = []byte(v.In(mc.cfg.loc).Format(timeFormat))
 				}
@@ -934,6 +1240,15 @@ func (stmt *mysqlStmt) writeExecutePacket(args []driver.Value) error {
 			}
 		}
 
+		if len(attrs) > 0 {
+			var err error
+			paramValues, err = mc.writeQueryAttributes(attrs, len(args),
+				nullMask, paramTypes[len(args)*typeLen:], paramValues)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Check if param values exceeded the available buffer
 		// In that case we must build the data packet with the new values buffer
 		if valuesCap != cap(paramValues) {
@@ -958,7 +1273,11 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 	// packet indicator [1 byte]
 	if data[0] != iOK {
 		// EOF Packet
-		if data[0] == iEOF && len(data) == 5 {
+		if eof, err := rows.mc.readEOFPacket(data); eof {
+			rows.warningCount = rows.mc.warningCount
+			if err != nil {
+				return err
+			}
 			return io.EOF
 		}
 
@@ -968,6 +1287,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 
 	// NULL-bitmap,  [(column-count + 7 + 2) / 8 bytes]
 	pos := 1 + (len(dest)+7+2)>>3
+	if len(data) < pos {
+		return rows.mc.malformedPacket()
+	}
 	nullMask := data[1:pos]
 
 	for i := range dest {
@@ -986,6 +1308,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 
 		// Numeric Types
 		case fieldTypeTiny:
+			if len(data) < pos+1 {
+				return rows.mc.malformedPacket()
+			}
 			if rows.columns[i].flags&flagUnsigned != 0 {
 				dest[i] = int64(data[pos])
 			} else {
@@ -995,6 +1320,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			continue
 
 		case fieldTypeShort, fieldTypeYear:
+			if len(data) < pos+2 {
+				return rows.mc.malformedPacket()
+			}
 			if rows.columns[i].flags&flagUnsigned != 0 {
 				dest[i] = int64(binary.LittleEndian.Uint16(data[pos : pos+2]))
 			} else {
@@ -1004,6 +1332,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			continue
 
 		case fieldTypeInt24, fieldTypeLong:
+			if len(data) < pos+4 {
+				return rows.mc.malformedPacket()
+			}
 			if rows.columns[i].flags&flagUnsigned != 0 {
 				dest[i] = int64(binary.LittleEndian.Uint32(data[pos : pos+4]))
 			} else {
@@ -1013,13 +1344,11 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			continue
 
 		case fieldTypeLongLong:
+			if len(data) < pos+8 {
+				return rows.mc.malformedPacket()
+			}
 			if rows.columns[i].flags&flagUnsigned != 0 {
-				val := binary.LittleEndian.Uint64(data[pos : pos+8])
-				if val > math.MaxInt64 {
-					dest[i] = uint64ToString(val)
-				} else {
-					dest[i] = int64(val)
-				}
+				dest[i] = binary.LittleEndian.Uint64(data[pos : pos+8])
 			} else {
 				dest[i] = int64(binary.LittleEndian.Uint64(data[pos : pos+8]))
 			}
@@ -1027,11 +1356,17 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			continue
 
 		case fieldTypeFloat:
+			if len(data) < pos+4 {
+				return rows.mc.malformedPacket()
+			}
 			dest[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[pos : pos+4])))
 			pos += 4
 			continue
 
 		case fieldTypeDouble:
+			if len(data) < pos+8 {
+				return rows.mc.malformedPacket()
+			}
 			dest[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[pos : pos+8]))
 			pos += 8
 			continue
@@ -1044,21 +1379,24 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			var isNull bool
 			var n int
 			dest[i], isNull, n, err = readLengthEncodedString(data[pos:])
+			if err != nil {
+				return rows.mc.malformedPacket()
+			}
 			pos += n
-			if err == nil {
-				if !isNull {
-					continue
-				} else {
-					dest[i] = nil
-					continue
-				}
+			if !isNull {
+				continue
+			} else {
+				dest[i] = nil
+				continue
 			}
-			return err
 
 		// Date YYYY-MM-DD
 		case fieldTypeDate, fieldTypeNewDate:
 			num, isNull, n := readLengthEncodedInteger(data[pos:])
 			pos += n
+			if pos > len(data) || num > uint64(len(data)-pos) {
+				return rows.mc.malformedPacket()
+			}
 
 			if isNull {
 				dest[i] = nil
@@ -1066,7 +1404,7 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			}
 
 			if rows.mc.parseTime {
-				dest[i], err = parseBinaryDateTime(num, data[pos:], rows.mc.cfg.loc)
+				dest[i], err = parseBinaryDateTime(num, data[pos:], rows.mc.cfg.loc, rows.mc.cfg.zeroDate)
 			} else {
 				dest[i], err = formatBinaryDateTime(data[pos:pos+int(num)], false)
 			}
@@ -1082,6 +1420,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 		case fieldTypeTime:
 			num, isNull, n := readLengthEncodedInteger(data[pos:])
 			pos += n
+			if pos > len(data) || num > uint64(len(data)-pos) {
+				return rows.mc.malformedPacket()
+			}
 
 			if num == 0 {
 				if isNull {
@@ -1127,6 +1468,9 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			num, isNull, n := readLengthEncodedInteger(data[pos:])
 
 			pos += n
+			if pos > len(data) || num > uint64(len(data)-pos) {
+				return rows.mc.malformedPacket()
+			}
 
 			if isNull {
 				dest[i] = nil
@@ -1134,7 +1478,7 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 			}
 
 			if rows.mc.parseTime {
-				dest[i], err = parseBinaryDateTime(num, data[pos:], rows.mc.cfg.loc)
+				dest[i], err = parseBinaryDateTime(num, data[pos:], rows.mc.cfg.loc, rows.mc.cfg.zeroDate)
 			} else {
 				dest[i], err = formatBinaryDateTime(data[pos:pos+int(num)], true)
 			}
@@ -1152,5 +1496,5 @@ func (rows *binaryRows) readRow(dest []driver.Value) error {
 		}
 	}
 
-	return nil
+	return rows.applyDecoders(dest)
 }
@@ -0,0 +1,352 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// mockConn is a net.Conn which reads from and writes to memory.
+// Queued responses are only read after everything else was read.
+type mockConn struct {
+	net.Conn
+	read    bytes.Buffer
+	queued  [][]byte
+	written bytes.Buffer
+}
+
+func (m *mockConn) Read(b []byte) (int, error) {
+	if m.read.Len() == 0 && len(m.queued) > 0 {
+		m.read.Write(m.queued[0])
+		m.queued = m.queued[1:]
+	}
+	return m.read.Read(b)
+}
+
+func (m *mockConn) Write(b []byte) (int, error) {
+	return m.written.Write(b)
+}
+
+func (m *mockConn) Close() error {
+	return nil
+}
+
+func newMockConn(flags clientFlag) (*mysqlConn, *mockConn) {
+	conn := new(mockConn)
+	mc := &mysqlConn{
+		buf:              newBuffer(conn),
+		netConn:          conn,
+		cfg:              &config{loc: time.UTC},
+		maxPacketAllowed: maxPacketSize,
+		maxWriteSize:     maxPacketSize - 1,
+		flags:            flags,
+	}
+	return mc, conn
+}
+
+func TestReadEOFPacket(t *testing.T) {
+	// row starting with a string of 8 bytes length
+	longRow := append([]byte{0xfe, 1, 0, 0, 0, 0, 0, 0, 0}, 'a')
+
+	var eofTests = []struct {
+		flags    clientFlag
+		data     []byte
+		eof      bool
+		warnings uint16
+		status   statusFlag
+	}{
+		{0, []byte{iOK, 0, 0, 2, 0, 0, 0}, false, 0, 0},
+		{0, []byte{iEOF, 3, 0, 2, 0}, true, 3, statusInAutocommit},
+		{0, []byte{iEOF}, true, 0, 0},
+		{0, longRow, false, 0, 0},
+
+		// OK packet with the header 0xfe
+		{clientDeprecateEOF, []byte{iEOF, 0, 0, 0x82, 0, 1, 0}, true, 1, statusInAutocommit | statusLastRowSent},
+		{clientDeprecateEOF, append([]byte{iEOF, 0, 0, 2, 0, 0, 0}, "info"...), true, 0, statusInAutocommit},
+		{clientDeprecateEOF, append([]byte{iEOF}, make([]byte, maxPacketSize)...), false, 0, 0},
+	}
+
+	for i, tst := range eofTests {
+		mc := &mysqlConn{cfg: &config{}, flags: tst.flags}
+		eof, err := mc.readEOFPacket(tst.data)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if eof != tst.eof {
+			t.Errorf("%d: expected eof %v, got %v", i, tst.eof, eof)
+		}
+		if mc.warningCount != tst.warnings || mc.status != tst.status {
+			t.Errorf("%d: expected %d warnings and status %x, got %d and %x", i, tst.warnings, tst.status, mc.warningCount, mc.status)
+		}
+	}
+}
+
+func TestWriteQueryAttributes(t *testing.T) {
+	ctx := WithQueryAttributes(context.Background(), map[string]interface{}{
+		"trace_id": "abc",
+		"tenant":   42,
+		"empty":    nil,
+	})
+
+	// COM_QUERY
+	mc, conn := newMockConn(clientQueryAttributes)
+	if err := mc.setQueryAttributes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.writeCommandPacketStr(comQuery, "DO 1"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		comQuery,
+		3, 1, // parameter count, parameter set count
+		0x01, // NULL-bitmap
+		0x01, // new params bind flag
+		fieldTypeNULL, 0x00, 5, 'e', 'm', 'p', 't', 'y',
+		fieldTypeLongLong, 0x00, 6, 't', 'e', 'n', 'a', 'n', 't',
+		fieldTypeString, 0x00, 8, 't', 'r', 'a', 'c', 'e', '_', 'i', 'd',
+		42, 0, 0, 0, 0, 0, 0, 0,
+		3, 'a', 'b', 'c',
+		'D', 'O', ' ', '1',
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], expected) {
+		t.Errorf("expected packet\n%v, got\n%v", expected, written[4:])
+	}
+
+	// the attributes are only sent once
+	conn.written.Reset()
+	if err := mc.writeCommandPacketStr(comQuery, "DO 1"); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], []byte{comQuery, 0, 1, 'D', 'O', ' ', '1'}) {
+		t.Errorf("unexpected packet %v", written[4:])
+	}
+
+	// not negotiated
+	mc, conn = newMockConn(0)
+	mc.setQueryAttributes(ctx)
+	if err := mc.writeCommandPacketStr(comQuery, "DO 1"); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], []byte{comQuery, 'D', 'O', ' ', '1'}) {
+		t.Errorf("unexpected packet %v", written[4:])
+	}
+}
+
+func TestWriteExecutePacketQueryAttributes(t *testing.T) {
+	ctx := WithQueryAttributes(context.Background(), map[string]interface{}{
+		"tenant": 42,
+	})
+
+	header := []byte{comStmtExecute, 1, 0, 0, 0}
+
+	// with a parameter
+	mc, conn := newMockConn(clientQueryAttributes)
+	stmt := &mysqlStmt{mc: mc, id: 1, paramCount: 1}
+	mc.setQueryAttributes(ctx)
+	if err := stmt.writeExecutePacket([]driver.Value{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := append(header,
+		0x00,       // flags
+		1, 0, 0, 0, // iteration count
+		2,    // parameter count
+		0x00, // NULL-bitmap
+		0x01, // new params bind flag
+		fieldTypeString, 0x00, 0,
+		fieldTypeLongLong, 0x00, 6, 't', 'e', 'n', 'a', 'n', 't',
+		1, 'a',
+		42, 0, 0, 0, 0, 0, 0, 0,
+	)
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], expected) {
+		t.Errorf("expected packet\n%v, got\n%v", expected, written[4:])
+	}
+
+	// without parameters
+	mc, conn = newMockConn(clientQueryAttributes)
+	stmt = &mysqlStmt{mc: mc, id: 1}
+	mc.setQueryAttributes(ctx)
+	if err := stmt.writeExecutePacket(nil); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(header,
+		cursorParameterCountAvailable,
+		1, 0, 0, 0,
+		1,
+		0x00,
+		0x01,
+		fieldTypeLongLong, 0x00, 6, 't', 'e', 'n', 'a', 'n', 't',
+		42, 0, 0, 0, 0, 0, 0, 0,
+	)
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], expected) {
+		t.Errorf("expected packet\n%v, got\n%v", expected, written[4:])
+	}
+
+	// without parameters and attributes
+	conn.written.Reset()
+	if err := stmt.writeExecutePacket(nil); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written[4:], append(header, 0x00, 1, 0, 0, 0)) {
+		t.Errorf("unexpected packet %v", written[4:])
+	}
+}
+
+func TestPing(t *testing.T) {
+	mc, conn := newMockConn(0)
+
+	// OK packet
+	conn.read.Write([]byte{7, 0, 0, 1, iOK, 0, 0, 2, 0, 0, 0})
+	if err := mc.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written, []byte{1, 0, 0, 0, comPing}) {
+		t.Errorf("expected COM_PING, got %v", written)
+	}
+
+	// connection closed by the server
+	if err := mc.Ping(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestResetSessionCommands(t *testing.T) {
+	okPacket := []byte{7, 0, 0, 1, iOK, 0, 0, 2, 0, 0, 0}
+
+	mc, conn := newMockConn(0)
+	mc.cfg.resetSession = true
+	mc.cfg.user = "gopher"
+	mc.sessionModified = true
+	stmt := &mysqlStmt{mc: mc, id: 1, generation: mc.generation}
+
+	conn.read.Write(okPacket)
+	if err := mc.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if written := conn.written.Bytes(); !bytes.Equal(written, []byte{1, 0, 0, 0, comResetConnection}) {
+		t.Errorf("expected COM_RESET_CONNECTION, got %v", written)
+	}
+	if mc.generation != 1 || mc.sessionModified {
+		t.Errorf("unexpected state after reset: generation %d, modified %v", mc.generation, mc.sessionModified)
+	}
+
+	// the statement was closed by the server
+	conn.written.Reset()
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if conn.written.Len() != 0 {
+		t.Errorf("expected no COM_STMT_CLOSE, got %v", conn.written.Bytes())
+	}
+
+	// fall back to COM_CHANGE_USER
+	conn.written.Reset()
+	conn.read.Write([]byte{5, 0, 0, 1, iERR, 0x17, 0x04, 'n', 'o'}) // ER_UNKNOWN_COM_ERROR
+	conn.queued = append(conn.queued, okPacket)
+	if err := mc.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	written := conn.written.Bytes()
+	if len(written) < 15 || written[9] != comChangeUser || string(written[10:16]) != "gopher" {
+		t.Errorf("expected COM_CHANGE_USER, got %v", written)
+	}
+	if !mc.noResetConn || mc.generation != 2 {
+		t.Errorf("unexpected state after reset: generation %d, noResetConn %v", mc.generation, mc.noResetConn)
+	}
+}
+
+func TestReprepareParamCountChanged(t *testing.T) {
+	mc, conn := newMockConn(0)
+	mc.generation = 1
+	stmt := &mysqlStmt{mc: mc, id: 1, query: "SELECT ?, ?", paramCount: 2}
+
+	// the statement 5 has 1 parameter
+	conn.read.Write(packet(1, []byte{iOK, 5, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}))
+	conn.read.Write(packet(2, fakeResultSet([]string{"?"})[1]))
+	conn.read.Write(packet(3, []byte{iEOF, 0x00, 0x00, 0x02, 0x00}))
+	if err := stmt.checkGeneration(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the new statement is closed
+	written := writtenPackets(t, conn.written.Bytes())
+	if len(written) != 2 || !bytes.Equal(written[1], []byte{comStmtClose, 5, 0, 0, 0}) {
+		t.Errorf("expected COM_STMT_CLOSE of statement 5, got %v", written)
+	}
+}
+
+func TestMalformedPackets(t *testing.T) {
+	logger := errLog
+	errLog = log.New(ioutil.Discard, "", 0)
+	defer func() { errLog = logger }()
+
+	columns := []mysqlField{{fieldType: fieldTypeLong}, {fieldType: fieldTypeVarString}}
+	tests := []struct {
+		name string
+		data []byte
+		read func(mc *mysqlConn) error
+	}{
+		{"OK packet", []byte{iOK, 0xfc, 0x01}, func(mc *mysqlConn) error {
+			return mc.readResultOK()
+		}},
+		{"ERR packet", []byte{iERR, 0x15}, func(mc *mysqlConn) error {
+			return mc.readResultOK()
+		}},
+		{"column count", []byte{0xfd, 0x01}, func(mc *mysqlConn) error {
+			_, err := mc.readResultSetHeaderPacket()
+			return err
+		}},
+		{"column definition", []byte("\x03def\x06gotest\x04test\x04test\x02id\x02id\x0c\x3f"), func(mc *mysqlConn) error {
+			_, err := mc.readColumns(1)
+			return err
+		}},
+		{"text row", []byte("\x0242\x10gopher"), func(mc *mysqlConn) error {
+			return (&textRows{mysqlRows{mc: mc, columns: columns}}).readRow(make([]driver.Value, 2))
+		}},
+		{"binary row", []byte{iOK, 0x00, 0x2a, 0x00}, func(mc *mysqlConn) error {
+			return (&binaryRows{mysqlRows{mc: mc, columns: columns}}).readRow(make([]driver.Value, 2))
+		}},
+		{"binary row length", []byte{iOK, 0x00, 0x2a, 0x00, 0x00, 0x00, 0xfe, 0xff}, func(mc *mysqlConn) error {
+			return (&binaryRows{mysqlRows{mc: mc, columns: columns}}).readRow(make([]driver.Value, 2))
+		}},
+		{"prepare result", []byte{iOK, 0x01, 0x00}, func(mc *mysqlConn) error {
+			_, err := (&mysqlStmt{mc: mc}).readPrepareResultPacket()
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		mc, conn := newMockConn(0)
+		conn.read.Write(packet(0, test.data))
+		if err := test.read(mc); err != errMalformPkt {
+			t.Errorf("%s: expected errMalformPkt, got %v", test.name, err)
+		}
+		if mc.IsValid() {
+			t.Errorf("%s: expected the connection to be closed", test.name)
+		}
+	}
+
+	// a valid column definition
+	mc, conn := newMockConn(0)
+	conn.read.Write(packet(0, []byte("\x03def\x06gotest\x04test\x04test\x02id\x02id\x0c\x3f\x00\x0b\x00\x00\x00\x03\x03\x42\x00\x00\x00")))
+	conn.queued = append(conn.queued, packet(1, []byte{iEOF, 0, 0, 2, 0}))
+	parsed, err := mc.readColumns(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed[0].name != "id" || parsed[0].fieldType != fieldTypeLong || parsed[0].length != 11 {
+		t.Errorf("unexpected column %+v", parsed[0])
+	}
+}
@@ -0,0 +1,354 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type readOnlyKey struct{}
+
+// ReadOnly returns a context which marks the queries executed with it as
+// read-only. A ReplicationConnector sends them to a replica, even if they
+// are no plain SELECT statements.
+//
+//  rows, err := db.QueryContext(mysql.ReadOnly(ctx), "SHOW TABLES")
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func isReadOnlyContext(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
+
+// Clauses and functions which lock rows or depend on or modify the session,
+// so that a SELECT must be sent to the primary
+var primaryTokens = []string{
+	"FOR UPDATE", "FOR SHARE", "LOCK IN SHARE MODE", "INTO", "@",
+	"LAST_INSERT_ID", "FOUND_ROWS", "ROW_COUNT",
+	"GET_LOCK", "RELEASE_LOCK", "IS_FREE_LOCK", "IS_USED_LOCK",
+}
+
+// isSessionQuery reports whether query changes the session, e.g. the default
+// database, session variables, temporary tables or table locks, which the
+// replicas don't have.
+func isSessionQuery(query string) bool {
+	// Only the first two words are relevant
+	if len(query) > 64 {
+		query = query[:64]
+	}
+	words := strings.Fields(strings.ToUpper(query))
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "SET", "USE":
+		return true
+	case "CREATE":
+		return len(words) > 1 && words[1] == "TEMPORARY"
+	case "LOCK":
+		return len(words) > 1 && (words[1] == "TABLES" || words[1] == "TABLE")
+	}
+	return false
+}
+
+// isReadQuery reports whether query is a plain SELECT which can be sent to a
+// replica. Queries which are not recognized are sent to the primary.
+func isReadQuery(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	if len(query) < 7 || !strings.EqualFold(query[:6], "SELECT") {
+		return false
+	}
+	switch query[6] {
+	case ' ', '\t', '\r', '\n', '*':
+	default:
+		return false
+	}
+
+	query = strings.ToUpper(query)
+	for _, token := range primaryTokens {
+		if strings.Contains(query, token) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReplicationConnector is a driver.Connector which splits reads and writes
+// between a primary and its replicas. Plain SELECT statements outside of
+// transactions and queries with a context marked by ReadOnly are sent to a
+// replica. All other statements, prepared statements and everything inside
+// of transactions are sent to the primary. Once the session of a connection
+// was modified, e.g. by SET, USE, CREATE TEMPORARY TABLE or LOCK TABLES, all
+// its queries are sent to the primary, until the session is reset (see the
+// DSN parameter resetSession).
+//
+// Each connection of the pool connects to a replica on its first read, in
+// round-robin order. Replicas which can't be reached are skipped for the
+// hostBackoff period of the primary DSN. Reads are sent to the primary if no
+// replica is available.
+//
+// If MaxReplicaLag is set, the replication lag of each replica is checked
+// periodically over a dedicated connection and replicas which lag behind by
+// more than MaxReplicaLag are skipped as well, until they caught up. The lag
+// checks are stopped by Close, which is called by sql.DB.Close.
+//
+//  connector, err := mysql.NewReplicationConnector(
+//  	"user:password@tcp(primary:3306)/dbname",
+//  	"user:password@tcp(replica1:3306)/dbname",
+//  	"user:password@tcp(replica2:3306)/dbname",
+//  )
+//  if err != nil {
+//  	...
+//  }
+//  db := sql.OpenDB(connector)
+type ReplicationConnector struct {
+	// Primary and Replicas establish the connections. They may be
+	// configured, e.g. with a Dialer, before the first connection.
+	Primary  *Connector
+	Replicas []*Connector
+
+	// MaxReplicaLag is the maximum replication lag of replicas used for
+	// reads. Zero disables the lag checks.
+	MaxReplicaLag time.Duration
+
+	// LagCheckInterval is the interval of the lag checks, one second by
+	// default. It is also the timeout of each check.
+	LagCheckInterval time.Duration
+
+	// LagQuery returns the replication lag in seconds, e.g. computed from the
+	// timestamp of a heartbeat table:
+	//  SELECT TIMESTAMPDIFF(MICROSECOND, MAX(ts), UTC_TIMESTAMP(6)) / 1e6 FROM heartbeat
+	// By default Seconds_Behind_Source of SHOW REPLICA STATUS is used.
+	LagQuery string
+
+	// GTIDWaitTimeout is the maximum time a replica waits for the GTIDs of a
+	// read with a context returned by WaitForGTIDs, one second by default.
+	// It is rounded up to whole seconds.
+	GTIDWaitTimeout time.Duration
+
+	replicas *hostList // health of the replicas, by index
+
+	lagOnce       sync.Once
+	lagMu         sync.Mutex
+	stale         map[string]bool // replicas which lag behind, by index
+	stopLagChecks context.CancelFunc
+	lagChecks     sync.WaitGroup
+}
+
+// NewReplicationConnector returns a ReplicationConnector for the DSN of the
+// primary and the DSNs of the replicas. Each DSN may have multiple hosts.
+func NewReplicationConnector(primaryDSN string, replicaDSNs ...string) (*ReplicationConnector, error) {
+	primary, err := NewConnector(primaryDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*Connector, len(replicaDSNs))
+	for i, dsn := range replicaDSNs {
+		if replicas[i], err = NewConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return newReplicationConnector(primary, replicas), nil
+}
+
+func newReplicationConnector(primary *Connector, replicas []*Connector) *ReplicationConnector {
+	keys := make([]string, len(replicas))
+	for i := range replicas {
+		keys[i] = strconv.Itoa(i)
+	}
+	return &ReplicationConnector{
+		Primary:  primary,
+		Replicas: replicas,
+		replicas: newHostList(&config{
+			addrs:       keys,
+			hostPolicy:  hostRoundRobin,
+			hostBackoff: primary.cfg.hostBackoff,
+		}),
+	}
+}
+
+// Driver implements driver.Connector.
+func (c *ReplicationConnector) Driver() driver.Driver {
+	return &MySQLDriver{}
+}
+
+// Connect implements driver.Connector. It connects to the primary, the
+// connection to a replica is established on the first read.
+func (c *ReplicationConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.startLagChecks()
+
+	conn, err := c.Primary.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &replicationConn{mysqlConn: conn.(*mysqlConn), connector: c}, nil
+}
+
+// replicationConn is the connection to the primary, which sends reads to a
+// replica. All other methods, including the Conn interface, refer to the
+// primary.
+type replicationConn struct {
+	*mysqlConn
+	connector  *ReplicationConnector
+	replica    *mysqlConn
+	replicaKey string
+}
+
+// QueryContext implements the driver.QueryerContext interface.
+func (rc *replicationConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	// Transactions, also those started by SET autocommit=0, and sessions with
+	// state only the primary has stay on the primary
+	if rc.InTransaction() || !rc.Autocommit() || rc.sessionModified ||
+		!(isReadOnlyContext(ctx) || isReadQuery(query)) {
+		rc.trackSession(query)
+		return rc.mysqlConn.QueryContext(ctx, query, args)
+	}
+
+	if replica := rc.replicaConn(ctx); replica != nil {
+		// Read-your-writes: the replica must have applied the GTIDs of ctx
+		caughtUp, err := replica.waitForGTIDs(ctx, rc.connector.GTIDWaitTimeout)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// The replica didn't fail, the deadline of ctx interrupted the wait
+			if !replica.IsValid() {
+				rc.closeReplica(false)
+			}
+			return nil, ctx.Err()
+		case err == errGTIDSet:
+			return nil, err
+		case err == driver.ErrBadConn:
+			rc.closeReplica(true)
+		case err != nil:
+			errLog.Print(err)
+		case caughtUp:
+			rows, err := replica.queryContext(ctx, query, args)
+			if err != driver.ErrBadConn {
+				return rows, err
+			}
+			rc.closeReplica(true)
+		}
+	}
+	return rc.mysqlConn.QueryContext(ctx, query, args)
+}
+
+// ExecContext implements the driver.ExecerContext interface.
+func (rc *replicationConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rc.trackSession(query)
+	return rc.mysqlConn.ExecContext(ctx, query, args)
+}
+
+// Prepare implements the driver.Conn interface.
+func (rc *replicationConn) Prepare(query string) (driver.Stmt, error) {
+	rc.trackSession(query)
+	return rc.mysqlConn.Prepare(query)
+}
+
+// Marks the session modified if query changes it. Servers only report some
+// of the changes with session tracking, e.g. not temporary tables unless
+// session_track_state_change is enabled.
+func (rc *replicationConn) trackSession(query string) {
+	if isSessionQuery(query) {
+		rc.sessionModified = true
+	}
+}
+
+// Returns the connection to a replica, or nil if no replica is available
+func (rc *replicationConn) replicaConn(ctx context.Context) *mysqlConn {
+	if rc.replica != nil {
+		if !rc.replica.IsValid() {
+			rc.closeReplica(true)
+		} else if rc.connector.isStale(rc.replicaKey) {
+			// The connection is kept until the replica caught up again
+			return nil
+		} else {
+			return rc.replica
+		}
+	}
+
+	replicas := rc.connector.replicas
+	for _, key := range replicas.available() {
+		if rc.connector.isStale(key) {
+			continue
+		}
+		i, _ := strconv.Atoi(key)
+		conn, err := rc.connector.Replicas[i].Connect(ctx)
+		if err == nil {
+			replicas.markUp(key)
+			rc.replica = conn.(*mysqlConn)
+			rc.replicaKey = key
+			return rc.replica
+		}
+		errLog.Print(err)
+		replicas.markDown(key)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Closes the connection to the replica. If the replica failed, it is
+// skipped by new connections for the backoff period.
+func (rc *replicationConn) closeReplica(failed bool) {
+	if failed {
+		rc.connector.replicas.markDown(rc.replicaKey)
+	}
+	rc.replica.Close()
+	rc.replica = nil
+}
+
+// ResetSession implements the driver.SessionResetter interface.
+// The connection to the replica is closed if it can't be reused, a new one
+// is established on the next read.
+func (rc *replicationConn) ResetSession(ctx context.Context) error {
+	if rc.replica != nil {
+		if err := rc.replica.ResetSession(ctx); err != nil {
+			rc.closeReplica(false)
+		}
+	}
+	return rc.mysqlConn.ResetSession(ctx)
+}
+
+// Close closes the connections to the primary and the replica.
+func (rc *replicationConn) Close() error {
+	if rc.replica != nil {
+		rc.closeReplica(false)
+	}
+	return rc.mysqlConn.Close()
+}
+
+// queryContext is like QueryContext, but sends queries with arguments as
+// one-off prepared statement instead of returning driver.ErrSkip
+func (mc *mysqlConn) queryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) == 0 {
+		return mc.QueryContext(ctx, query, args)
+	}
+
+	stmt, err := mc.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.(*mysqlStmt).QueryContext(ctx, args)
+	if err != nil {
+		if mc.IsValid() {
+			stmt.Close()
+		}
+		return nil, err
+	}
+	rows.(*binaryRows).stmt = stmt.(*mysqlStmt)
+	return rows, nil
+}
@@ -0,0 +1,225 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"SELECT 1":                                  true,
+		"  select * FROM t WHERE id = ?":            true,
+		"(SELECT a FROM t) UNION (SELECT b FROM u)": true,
+		"SELECT*FROM t":                             true,
+		"SELECT * FROM t FOR UPDATE":                false,
+		"SELECT * FROM t LOCK IN SHARE MODE":        false,
+		"select * from t for share":                 false,
+		"SELECT a INTO @a FROM t":                   false,
+		"SELECT @@read_only":                        false,
+		"SELECT LAST_INSERT_ID()":                   false,
+		"SELECT GET_LOCK('lock', 10)":               false,
+		"SELECTED":                                  false,
+		"SHOW TABLES":                               false,
+		"INSERT INTO t SELECT * FROM u":             false,
+		"/* comment */ SELECT 1":                    false,
+	} {
+		if got := isReadQuery(query); got != want {
+			t.Errorf("isReadQuery(%q) = %t, want %t", query, got, want)
+		}
+	}
+}
+
+// fakeReplication returns a ReplicationConnector with a fake primary and
+// replica. Both answer queries with their name and log them. The replica
+// reports lag as Seconds_Behind_Source and has applied the GTIDs 1-5.
+func fakeReplication(t *testing.T, lag *int32) (*ReplicationConnector, func() []string) {
+	connector, err := NewReplicationConnector("user@tcp(primary:3306)/dbname", "user@tcp(replica:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var queries []string
+	handler := func(name string) fakeHandler {
+		return func(query string) [][]byte {
+			mu.Lock()
+			queries = append(queries, name+": "+query)
+			mu.Unlock()
+
+			switch {
+			case query == "SHOW REPLICA STATUS" && lag != nil:
+				seconds := strconv.Itoa(int(atomic.LoadInt32(lag)))
+				return fakeResultSet([]string{"Seconds_Behind_Source"}, []string{seconds})
+			case strings.HasPrefix(query, "SELECT WAIT_FOR_EXECUTED_GTID_SET("):
+				if strings.Contains(query, ":1-5'") {
+					return fakeResultSet([]string{"wait"}, []string{"0"})
+				}
+				return fakeResultSet([]string{"wait"}, []string{"1"})
+			case query == "START TRANSACTION":
+				return [][]byte{{iOK, 0, 0, byte(statusInTrans | statusInAutocommit), 0, 0, 0}}
+			case strings.HasPrefix(query, "PREPARE "):
+				// 1 parameter and 1 column
+				return append(append([][]byte{{iOK, 1, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0}},
+					fakeResultSet([]string{"?"})[1:3]...), fakeResultSet([]string{"name"})[1:3]...)
+			case query == "EXECUTE":
+				rs := fakeResultSet([]string{"name"})
+				row := appendLengthEncodedString([]byte{0x00, 0x00}, name)
+				return append(rs[:3], row, rs[3])
+			case strings.HasPrefix(query, "SELECT") || strings.HasPrefix(query, "SHOW"):
+				return fakeResultSet([]string{"name"}, []string{name})
+			}
+			return nil
+		}
+	}
+	connector.Primary.Dialer = fakeDialer(handler("primary"))
+	connector.Replicas[0].Dialer = fakeDialer(handler("replica"))
+
+	return connector, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestReplicationConnector(t *testing.T) {
+	connector, queries := fakeReplication(t, nil)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, tst := range []struct {
+		ctx   context.Context
+		query string
+		args  []interface{}
+		want  string
+	}{
+		{ctx, "SELECT name", nil, "replica"},
+		{ctx, "SELECT name FOR UPDATE", nil, "primary"},
+		{ctx, "SHOW TABLES", nil, "primary"},
+		{ReadOnly(ctx), "SHOW TABLES", nil, "replica"},
+		{ctx, "SELECT name WHERE id = ?", []interface{}{1}, "replica"},
+	} {
+		var name string
+		if err := db.QueryRowContext(tst.ctx, tst.query, tst.args...).Scan(&name); err != nil {
+			t.Fatalf("%s: %v", tst.query, err)
+		}
+		if name != tst.want {
+			t.Errorf("%s: expected %s, got %s", tst.query, tst.want, name)
+		}
+	}
+
+	// transactions stay on the primary
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err = tx.QueryRow("SELECT name").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "primary" {
+		t.Errorf("transaction: expected primary, got %s", name)
+	}
+	tx.Rollback()
+
+	// the one-off statement of the query with arguments was closed
+	if err = db.QueryRow("SELECT name").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	var closed bool
+	for _, query := range queries() {
+		closed = closed || query == "replica: CLOSE"
+	}
+	if !closed {
+		t.Errorf("expected the statement to be closed: %q", queries())
+	}
+}
+
+func TestReplicationConnectorReplicaDown(t *testing.T) {
+	connector, _ := fakeReplication(t, nil)
+	var dials int
+	connector.Replicas[0].Dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		dials++
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for i := 0; i < 2; i++ {
+		var name string
+		if err := db.QueryRow("SELECT name").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name != "primary" {
+			t.Errorf("expected primary, got %s", name)
+		}
+	}
+
+	// the replica is skipped during the backoff period
+	if dials != 1 {
+		t.Errorf("expected 1 dial of the replica, got %d", dials)
+	}
+}
+
+func TestIsSessionQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"SET time_zone = '+00:00'":         true,
+		"set\tNAMES utf8mb4":               true,
+		"USE otherdb":                      true,
+		"CREATE TEMPORARY TABLE t (a INT)": true,
+		"LOCK TABLES t READ":               true,
+		"CREATE TABLE t (a INT)":           false,
+		"SELECT * FROM settings":           false,
+		"UPDATE t SET a = 1":               false,
+		"":                                 false,
+	} {
+		if got := isSessionQuery(query); got != want {
+			t.Errorf("isSessionQuery(%q) = %t, want %t", query, got, want)
+		}
+	}
+}
+
+func TestReplicationConnectorSessionState(t *testing.T) {
+	for _, stmt := range []string{"SET time_zone = '+01:00'", "CREATE TEMPORARY TABLE t (a INT)"} {
+		connector, _ := fakeReplication(t, nil)
+		db := sql.OpenDB(connector)
+		db.SetMaxOpenConns(1)
+
+		var name string
+		if err := db.QueryRow("SELECT name").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name != "replica" {
+			t.Errorf("%s: expected replica before, got %s", stmt, name)
+		}
+
+		// the replica doesn't have the session state of the primary
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("SELECT name").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name != "primary" {
+			t.Errorf("%s: expected primary after, got %s", stmt, name)
+		}
+		db.Close()
+	}
+}
@@ -8,9 +8,88 @@
 
 package mysql
 
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+)
+
+// StatusFlag is the set of server status flags sent with a result.
+type StatusFlag uint16
+
+// Server status flags
+const (
+	StatusInTrans             = StatusFlag(statusInTrans)
+	StatusInAutocommit        = StatusFlag(statusInAutocommit)
+	StatusMoreResultsExists   = StatusFlag(statusMoreResultsExists)
+	StatusNoGoodIndexUsed     = StatusFlag(statusNoGoodIndexUsed)
+	StatusNoIndexUsed         = StatusFlag(statusNoIndexUsed)
+	StatusCursorExists        = StatusFlag(statusCursorExists)
+	StatusLastRowSent         = StatusFlag(statusLastRowSent)
+	StatusDbDropped           = StatusFlag(statusDbDropped)
+	StatusNoBackslashEscapes  = StatusFlag(statusNoBackslashEscapes)
+	StatusMetadataChanged     = StatusFlag(statusMetadataChanged)
+	StatusQueryWasSlow        = StatusFlag(statusQueryWasSlow)
+	StatusPsOutParams         = StatusFlag(statusPsOutParams)
+	StatusInTransReadonly     = StatusFlag(statusInTransReadonly)
+	StatusSessionStateChanged = StatusFlag(statusSessionStateChanged)
+)
+
+// Result is implemented by the results of Exec. database/sql hides the
+// driver result, so the connection must be used directly:
+//
+//  err := conn.Raw(func(driverConn interface{}) error {
+//  	res, err := driverConn.(driver.Execer).Exec(query, args)
+//  	if err != nil {
+//  		return err
+//  	}
+//  	matched, _ := res.(mysql.Result).MatchedRows()
+//  ...
+type Result interface {
+	driver.Result
+
+	// AffectedRows returns the number of rows changed, inserted or
+	// deleted by the statement. With clientFoundRows=true the number of
+	// matched rows is returned for UPDATE statements instead.
+	AffectedRows() uint64
+
+	// LastInsertID returns the first AUTO_INCREMENT value generated by
+	// the statement.
+	LastInsertID() uint64
+
+	// InsertIDRange returns the first and last AUTO_INCREMENT value
+	// generated by a (multi-row) INSERT. The range is only contiguous
+	// with innodb_autoinc_lock_mode 0 or 1 (consecutive) and if
+	// auto_increment_increment is 1.
+	// ok is false if the statement didn't generate any values.
+	InsertIDRange() (first, last uint64, ok bool)
+
+	// MatchedRows returns the number of rows matched by an UPDATE.
+	// ok is false if the server didn't report it.
+	MatchedRows() (matched uint64, ok bool)
+
+	// ChangedRows returns the number of rows actually changed by an
+	// UPDATE. ok is false if the server didn't report it.
+	ChangedRows() (changed uint64, ok bool)
+
+	// Info returns the human-readable information sent by the server,
+	// e.g. "Rows matched: 3  Changed: 1  Warnings: 0".
+	Info() string
+
+	// Status returns the server status flags after the statement.
+	Status() StatusFlag
+
+	// WarningCount returns the number of warnings generated by the
+	// statement.
+	WarningCount() uint16
+}
+
 type mysqlResult struct {
 	affectedRows int64
 	insertId     int64
+	warningCount uint16
+	status       statusFlag
+	info         string
 }
 
 func (res *mysqlResult) LastInsertId() (int64, error) {
@@ -20,3 +99,69 @@ func (res *mysqlResult) LastInsertId() (int64, error) {
 func (res *mysqlResult) RowsAffected() (int64, error) {
 	return res.affectedRows, nil
 }
+
+func (res *mysqlResult) AffectedRows() uint64 {
+	return uint64(res.affectedRows)
+}
+
+func (res *mysqlResult) LastInsertID() uint64 {
+	return uint64(res.insertId)
+}
+
+func (res *mysqlResult) InsertIDRange() (first, last uint64, ok bool) {
+	first = uint64(res.insertId)
+	if first == 0 {
+		return 0, 0, false
+	}
+
+	// INSERT ... VALUES (...), (...) reports the number of records and
+	// the duplicates, otherwise use the affected rows
+	count := uint64(res.affectedRows)
+	if records, ok := infoValue(res.info, "Records"); ok {
+		duplicates, _ := infoValue(res.info, "Duplicates")
+		count = records - duplicates
+	}
+	if count == 0 {
+		count = 1
+	}
+	return first, first + count - 1, true
+}
+
+func (res *mysqlResult) MatchedRows() (uint64, bool) {
+	return infoValue(res.info, "Rows matched")
+}
+
+func (res *mysqlResult) ChangedRows() (uint64, bool) {
+	return infoValue(res.info, "Changed")
+}
+
+func (res *mysqlResult) Info() string {
+	return res.info
+}
+
+func (res *mysqlResult) Status() StatusFlag {
+	return StatusFlag(res.status)
+}
+
+// WarningCount returns the number of warnings generated by the statement
+func (res *mysqlResult) WarningCount() uint16 {
+	return res.warningCount
+}
+
+// infoValue returns the number following "<key>: " in an info string like
+// "Records: 3  Duplicates: 0  Warnings: 0", where keys are separated by
+// two spaces
+func infoValue(info, key string) (uint64, bool) {
+	pos := strings.Index(info, key+": ")
+	if pos < 0 || (pos > 0 && !strings.HasSuffix(info[:pos], "  ")) {
+		return 0, false
+	}
+	pos += len(key) + 2
+
+	end := pos
+	for end < len(info) && info[end] >= '0' && info[end] <= '9' {
+		end++
+	}
+	value, err := strconv.ParseUint(info[pos:end], 10, 64)
+	return value, err == nil
+}
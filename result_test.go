@@ -0,0 +1,92 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"testing"
+)
+
+func TestHandleOkPacket(t *testing.T) {
+	mc := &mysqlConn{cfg: &config{}}
+
+	// affected rows 1, insert id 0, status autocommit, 0 warnings, info
+	data := append([]byte{iOK, 1, 0, 0x02, 0x00, 0x00, 0x00}, "Rows matched: 3  Changed: 1  Warnings: 0"...)
+	if err := mc.handleOkPacket(data); err != nil {
+		t.Fatal(err)
+	}
+
+	res := Result(&mysqlResult{
+		affectedRows: int64(mc.affectedRows),
+		insertId:     int64(mc.insertId),
+		warningCount: mc.warningCount,
+		status:       mc.status,
+		info:         mc.info,
+	})
+	if res.AffectedRows() != 1 {
+		t.Errorf("expected 1 affected row, got %d", res.AffectedRows())
+	}
+	if res.Status() != StatusInAutocommit {
+		t.Errorf("expected status %x, got %x", StatusInAutocommit, res.Status())
+	}
+	if matched, ok := res.MatchedRows(); !ok || matched != 3 {
+		t.Errorf("expected 3 matched rows, got %d (%v)", matched, ok)
+	}
+	if changed, ok := res.ChangedRows(); !ok || changed != 1 {
+		t.Errorf("expected 1 changed row, got %d (%v)", changed, ok)
+	}
+	if _, _, ok := res.InsertIDRange(); ok {
+		t.Error("expected no insert ID range")
+	}
+
+	// old servers may omit status, warnings and info
+	if err := mc.handleOkPacket([]byte{iOK, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResultInsertIDRange(t *testing.T) {
+	var rangeTests = []struct {
+		res         mysqlResult
+		first, last uint64
+	}{
+		{mysqlResult{affectedRows: 1, insertId: 5}, 5, 5},
+		{mysqlResult{affectedRows: 3, insertId: 5, info: "Records: 3  Duplicates: 0  Warnings: 0"}, 5, 7},
+		// ON DUPLICATE KEY UPDATE counts updated rows twice
+		{mysqlResult{affectedRows: 4, insertId: 5, info: "Records: 3  Duplicates: 1  Warnings: 0"}, 5, 6},
+		// BIGINT UNSIGNED beyond the int64 range
+		{mysqlResult{affectedRows: 1, insertId: -2}, 1<<64 - 2, 1<<64 - 2},
+	}
+	for i, tst := range rangeTests {
+		first, last, ok := tst.res.InsertIDRange()
+		if !ok || first != tst.first || last != tst.last {
+			t.Errorf("%d: expected [%d, %d], got [%d, %d] (%v)", i, tst.first, tst.last, first, last, ok)
+		}
+	}
+}
+
+func TestInfoValue(t *testing.T) {
+	const info = "Rows matched: 3  Changed: 12  Warnings: 0"
+	var infoTests = []struct {
+		key   string
+		value uint64
+		ok    bool
+	}{
+		{"Rows matched", 3, true},
+		{"Changed", 12, true},
+		{"Warnings", 0, true},
+		{"matched", 0, false},
+		{"Records", 0, false},
+	}
+	for _, tst := range infoTests {
+		value, ok := infoValue(info, tst.key)
+		if value != tst.value || ok != tst.ok {
+			t.Errorf("%s: expected %d (%v), got %d (%v)", tst.key, tst.value, tst.ok, value, ok)
+		}
+	}
+}
@@ -13,15 +13,11 @@ import (
 	"io"
 )
 
-type mysqlField struct {
-	fieldType byte
-	flags     fieldFlag
-	name      string
-}
-
 type mysqlRows struct {
-	mc      *mysqlConn
-	columns []mysqlField
+	mc           *mysqlConn
+	columns      []mysqlField
+	warningCount uint16
+	stmt         *mysqlStmt // one-off statement, closed with the rows
 }
 
 type binaryRows struct {
@@ -40,10 +36,36 @@ func (rows *mysqlRows) Columns() []string {
 	return columns
 }
 
+// ColumnTypeDatabaseTypeName implements the
+// driver.RowsColumnTypeDatabaseTypeName interface.
+func (rows *mysqlRows) ColumnTypeDatabaseTypeName(i int) string {
+	return rows.columns[i].typeDatabaseName()
+}
+
+// ColumnTypePrecisionScale implements the
+// driver.RowsColumnTypePrecisionScale interface.
+// Precision and scale are only reported for DECIMAL columns.
+func (rows *mysqlRows) ColumnTypePrecisionScale(i int) (int64, int64, bool) {
+	mf := &rows.columns[i]
+	switch mf.fieldType {
+	case fieldTypeDecimal, fieldTypeNewDecimal:
+		// the display length includes the decimal point and the sign
+		precision := int64(mf.length)
+		if mf.decimals > 0 {
+			precision--
+		}
+		if mf.flags&flagUnsigned == 0 {
+			precision--
+		}
+		return precision, int64(mf.decimals), true
+	}
+	return 0, 0, false
+}
+
 func (rows *mysqlRows) Close() error {
 	mc := rows.mc
 	if mc == nil {
-		return nil
+		return rows.closeStmt()
 	}
 	if mc.netConn == nil {
 		return errInvalidConn
@@ -51,8 +73,27 @@ func (rows *mysqlRows) Close() error {
 
 	// Remove unread packets from stream
 	err := mc.readUntilEOF()
+	rows.warningCount = mc.warningCount
 	rows.mc = nil
-	return err
+	if err != nil {
+		return err
+	}
+	return rows.closeStmt()
+}
+
+func (rows *mysqlRows) closeStmt() error {
+	stmt := rows.stmt
+	if stmt == nil {
+		return nil
+	}
+	rows.stmt = nil
+	return stmt.Close()
+}
+
+// WarningCount returns the number of warnings generated by the query.
+// It is only available after all rows were read.
+func (rows *mysqlRows) WarningCount() uint16 {
+	return rows.warningCount
 }
 
 func (rows *binaryRows) Next(dest []driver.Value) error {
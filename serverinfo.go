@@ -0,0 +1,123 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ServerFlavor is the server implementation detected from the handshake.
+type ServerFlavor uint8
+
+// Server flavors
+const (
+	FlavorMySQL ServerFlavor = iota
+	FlavorMariaDB
+	FlavorPercona
+)
+
+func (f ServerFlavor) String() string {
+	switch f {
+	case FlavorMariaDB:
+		return "MariaDB"
+	case FlavorPercona:
+		return "Percona"
+	default:
+		return "MySQL"
+	}
+}
+
+// ServerInfo is the information sent by the server in the handshake
+// greeting. It is available from the Conn interface:
+//
+//  err := conn.Raw(func(driverConn interface{}) error {
+//  	info := driverConn.(mysql.Conn).ServerInfo()
+//  	if info.Flavor == mysql.FlavorMySQL && info.VersionAtLeast(8, 0, 23) {
+//  ...
+type ServerInfo struct {
+	// Version is the server version, e.g. "8.0.32" or "10.6.12-MariaDB".
+	// The "5.5.5-" prefix of MariaDB versions is removed.
+	Version string
+
+	// Flavor is detected from the version and the capabilities. Percona
+	// Server is only detected if its version names it, e.g. by the
+	// version_suffix system variable.
+	Flavor ServerFlavor
+
+	// ConnectionID is the id of the connection on the server.
+	ConnectionID uint32
+
+	// Capabilities are the capability flags supported by the server.
+	Capabilities uint32
+
+	// MariaDBCapabilities are the extended capability flags of MariaDB
+	// 10.2+ servers.
+	MariaDBCapabilities uint32
+
+	// Collation is the id of the default collation of the server.
+	Collation uint8
+
+	// Status are the server status flags at the time of the handshake.
+	Status StatusFlag
+
+	// AuthPlugin is the default authentication plugin of the server, e.g.
+	// "caching_sha2_password".
+	AuthPlugin string
+}
+
+// VersionAtLeast reports whether the server version is at least
+// major.minor.patch.
+func (info ServerInfo) VersionAtLeast(major, minor, patch int) bool {
+	v := parseVersion(info.Version)
+	want := [3]int{major, minor, patch}
+	for i := range v {
+		if v[i] != want[i] {
+			return v[i] > want[i]
+		}
+	}
+	return true
+}
+
+// Parses the numeric part of a version like "8.0.32-log". Missing parts
+// are 0.
+func parseVersion(version string) (v [3]int) {
+	for i := range v {
+		end := 0
+		for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+			end++
+		}
+		v[i], _ = strconv.Atoi(version[:end])
+		if end == len(version) || version[end] != '.' {
+			break
+		}
+		version = version[end+1:]
+	}
+	return
+}
+
+func detectFlavor(version string, mariaDBCapabilities uint32) ServerFlavor {
+	switch {
+	case mariaDBCapabilities != 0 || strings.Contains(version, "MariaDB"):
+		return FlavorMariaDB
+	case strings.Contains(strings.ToLower(version), "percona"):
+		return FlavorPercona
+	default:
+		return FlavorMySQL
+	}
+}
+
+// MariaDB 10.x sends "5.5.5-" in front of its version for the replication
+// protocol of older MySQL clients
+func trimMariaDBPrefix(version string) string {
+	if strings.HasPrefix(version, "5.5.5-") && strings.Contains(version, "MariaDB") {
+		return version[len("5.5.5-"):]
+	}
+	return version
+}
@@ -0,0 +1,144 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"testing"
+)
+
+// greeting returns a handshake packet of a server
+func greeting(version string, flags clientFlag, mariaDBCapabilities uint32, rest ...[]byte) []byte {
+	payload := [][]byte{
+		{10},
+		[]byte(version + "\x00"),
+		{0x2a, 0x01, 0x00, 0x00}, // connection id
+		testScramble[:8],
+		{0x00},
+		{byte(flags), byte(flags >> 8)},
+		{collation_utf8mb4_general_ci, byte(statusInAutocommit), 0x00},
+		{byte(flags >> 16), byte(flags >> 24)},
+		{21},
+		make([]byte, 6),
+		{byte(mariaDBCapabilities), byte(mariaDBCapabilities >> 8), byte(mariaDBCapabilities >> 16), byte(mariaDBCapabilities >> 24)},
+	}
+	return packet(0, append(payload, rest...)...)
+}
+
+func TestReadInitPacket(t *testing.T) {
+	mysqlFlags := clientLongPassword | clientProtocol41 | clientSecureConn | clientPluginAuth
+	mariaDBFlags := clientProtocol41 | clientSecureConn | clientPluginAuth
+
+	tests := []struct {
+		greeting []byte
+		info     ServerInfo
+	}{
+		{
+			greeting(
+				"8.0.32", mysqlFlags, 0,
+				testScramble[8:], []byte{0x00}, []byte(authCachingSHA2Password+"\x00"),
+			),
+			ServerInfo{
+				Version:      "8.0.32",
+				Flavor:       FlavorMySQL,
+				ConnectionID: 298,
+				Capabilities: uint32(mysqlFlags),
+				Collation:    collation_utf8mb4_general_ci,
+				Status:       StatusInAutocommit,
+				AuthPlugin:   authCachingSHA2Password,
+			},
+		},
+		{
+			greeting(
+				"5.5.5-10.6.12-MariaDB-log", mariaDBFlags, 0x1d,
+				testScramble[8:], []byte{0x00}, []byte(authNativePassword+"\x00"),
+			),
+			ServerInfo{
+				Version:             "10.6.12-MariaDB-log",
+				Flavor:              FlavorMariaDB,
+				ConnectionID:        298,
+				Capabilities:        uint32(mariaDBFlags),
+				MariaDBCapabilities: 0x1d,
+				Collation:           collation_utf8mb4_general_ci,
+				Status:              StatusInAutocommit,
+				AuthPlugin:          authNativePassword,
+			},
+		},
+		{
+			// auth plugin name terminated by EOF
+			greeting(
+				"5.5.8-percona-log", mysqlFlags, 0,
+				testScramble[8:], []byte{0x00}, []byte(authNativePassword),
+			),
+			ServerInfo{
+				Version:      "5.5.8-percona-log",
+				Flavor:       FlavorPercona,
+				ConnectionID: 298,
+				Capabilities: uint32(mysqlFlags),
+				Collation:    collation_utf8mb4_general_ci,
+				Status:       StatusInAutocommit,
+				AuthPlugin:   authNativePassword,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		mc, conn := newMockConn(0)
+		conn.read.Write(test.greeting)
+		cipher, err := mc.readInitPacket()
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(cipher, testScramble) {
+			t.Errorf("%d: unexpected cipher %v", i, cipher)
+		}
+		if mc.ServerInfo() != test.info {
+			t.Errorf("%d: expected %+v, got %+v", i, test.info, mc.ServerInfo())
+		}
+	}
+
+	// scramble without terminator
+	mc, conn := newMockConn(0)
+	conn.read.Write(greeting("8.0.32", mysqlFlags, 0, testScramble[8:], []byte{0x01}))
+	if _, err := mc.readInitPacket(); err != errMalformPkt {
+		t.Errorf("expected errMalformPkt, got %v", err)
+	}
+
+	// truncated packet
+	mc, conn = newMockConn(0)
+	conn.read.Write(packet(0, []byte{10}, []byte("8.0.32\x00"), []byte{0x2a, 0x01}))
+	if _, err := mc.readInitPacket(); err != errMalformPkt {
+		t.Errorf("expected errMalformPkt, got %v", err)
+	}
+}
+
+func TestServerInfoVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version             string
+		major, minor, patch int
+		expected            bool
+	}{
+		{"8.0.32", 8, 0, 23, true},
+		{"8.0.32", 8, 0, 32, true},
+		{"8.0.32", 8, 0, 33, false},
+		{"8.0.32-log", 8, 1, 0, false},
+		{"5.7.41-44-log", 5, 7, 0, true},
+		{"10.6.12-MariaDB", 10, 2, 0, true},
+		{"10.6.12-MariaDB", 10, 10, 0, false},
+		{"9.1", 9, 1, 0, true},
+		{"invalid", 5, 0, 0, false},
+	}
+	for _, test := range tests {
+		info := ServerInfo{Version: test.version}
+		if got := info.VersionAtLeast(test.major, test.minor, test.patch); got != test.expected {
+			t.Errorf("%s at least %d.%d.%d: expected %v, got %v", test.version, test.major, test.minor, test.patch, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// Types of session state changes
+// http://dev.mysql.com/doc/internals/en/packet-OK_Packet.html
+const (
+	sessionTrackSystemVariables byte = iota
+	sessionTrackSchema
+	sessionTrackStateChange
+	sessionTrackGTIDs
+	sessionTrackTransactionCharacteristics
+	sessionTrackTransactionState
+)
+
+// SessionState contains the changes of the session state reported by the
+// server after a statement. Which changes are reported is configured by the
+// session_track_* system variables of the server, which can be set in the
+// DSN, e.g. session_track_gtids=OWN_GTID.
+// Fields of changes which were not reported are empty.
+type SessionState struct {
+	// SystemVariables contains the changed system variables and their
+	// new values (session_track_system_variables).
+	SystemVariables map[string]string
+
+	// Schema is the new default schema (session_track_schema).
+	Schema string
+
+	// StateChanged is true if the session state was changed in any way,
+	// e.g. by setting user variables (session_track_state_change).
+	StateChanged bool
+
+	// GTIDs is the GTID set of the transactions committed by the
+	// statement (session_track_gtids).
+	GTIDs string
+
+	// TransactionCharacteristics is a statement which restores the
+	// characteristics of the current transaction
+	// (session_track_transaction_info=CHARACTERISTICS).
+	TransactionCharacteristics string
+
+	// TransactionState describes the state of the current transaction
+	// (session_track_transaction_info).
+	TransactionState string
+}
+
+var sessionStateHandler func(state *SessionState)
+
+// SetSessionStateHandler sets a function which is called with the session
+// state changes after each statement for which the server reported any.
+// The handler is shared by all connections and must not keep the state.
+// Use nil to remove the handler.
+//
+//  mysql.SetSessionStateHandler(func(state *mysql.SessionState) {
+//  	if state.GTIDs != "" {
+//  		lastGTID.Store(state.GTIDs)
+//  	}
+//  })
+func SetSessionStateHandler(handler func(state *SessionState)) {
+	sessionStateHandler = handler
+}
+
+// SessionState returns the session state changes reported by the server
+// for the last statement executed with Exec on the connection, or nil if
+// there were none. Requires a server supporting session tracking (MySQL 5.7+).
+// The connection can be accessed by sql.Conn.Raw.
+func (mc *mysqlConn) SessionState() *SessionState {
+	return mc.sessionState
+}
+
+// Parses the session state info of an OK packet
+func (mc *mysqlConn) handleSessionState(data []byte) error {
+	state, err := parseSessionState(data)
+	if err != nil {
+		return err
+	}
+	mc.sessionState = state
+
+	// Changes of the GTIDs or of the transaction state don't change the
+	// session itself
+	if state.StateChanged || state.Schema != "" || len(state.SystemVariables) > 0 {
+		mc.sessionModified = true
+	}
+
+	if handler := sessionStateHandler; handler != nil {
+		handler(state)
+	}
+	return nil
+}
+
+func parseSessionState(data []byte) (*SessionState, error) {
+	// session state info [length encoded string]
+	if len(data) == 0 {
+		return nil, errMalformPkt
+	}
+	info, _, _, err := readLengthEncodedString(data)
+	if err != nil {
+		return nil, errMalformPkt
+	}
+
+	state := &SessionState{}
+	for len(info) > 0 {
+		// type [1 byte]
+		typ := info[0]
+		if len(info) < 2 {
+			return nil, errMalformPkt
+		}
+
+		// data [length encoded string]
+		entry, _, n, err := readLengthEncodedString(info[1:])
+		if err != nil {
+			return nil, errMalformPkt
+		}
+		info = info[1+n:]
+
+		switch typ {
+		case sessionTrackSystemVariables:
+			// name [length encoded string]
+			// value [length encoded string]
+			name, value, err := readLengthEncodedPair(entry)
+			if err != nil {
+				return nil, err
+			}
+			if state.SystemVariables == nil {
+				state.SystemVariables = make(map[string]string)
+			}
+			state.SystemVariables[name] = value
+
+		case sessionTrackSchema:
+			schema, err := readLengthEncodedField(entry)
+			if err != nil {
+				return nil, err
+			}
+			state.Schema = schema
+
+		case sessionTrackStateChange:
+			changed, err := readLengthEncodedField(entry)
+			if err != nil {
+				return nil, err
+			}
+			state.StateChanged = changed == "1"
+
+		case sessionTrackGTIDs:
+			// encoding specification [1 byte], always 0
+			// GTIDs [length encoded string]
+			if len(entry) < 1 {
+				return nil, errMalformPkt
+			}
+			gtids, err := readLengthEncodedField(entry[1:])
+			if err != nil {
+				return nil, err
+			}
+			state.GTIDs = gtids
+
+		case sessionTrackTransactionCharacteristics:
+			characteristics, err := readLengthEncodedField(entry)
+			if err != nil {
+				return nil, err
+			}
+			state.TransactionCharacteristics = characteristics
+
+		case sessionTrackTransactionState:
+			txState, err := readLengthEncodedField(entry)
+			if err != nil {
+				return nil, err
+			}
+			state.TransactionState = txState
+		}
+		// unknown types are skipped
+	}
+	return state, nil
+}
+
+// reads a single length encoded string as string
+func readLengthEncodedField(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", errMalformPkt
+	}
+	field, _, _, err := readLengthEncodedString(b)
+	if err != nil {
+		return "", err
+	}
+	return string(field), nil
+}
+
+// reads two consecutive length encoded strings as strings
+func readLengthEncodedPair(b []byte) (string, string, error) {
+	if len(b) == 0 {
+		return "", "", errMalformPkt
+	}
+	first, _, n, err := readLengthEncodedString(b)
+	if err != nil {
+		return "", "", errMalformPkt
+	}
+	second, err := readLengthEncodedField(b[n:])
+	if err != nil {
+		return "", "", err
+	}
+	return string(first), second, nil
+}
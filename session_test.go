@@ -0,0 +1,89 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"testing"
+)
+
+// appends a session state entry to b
+func appendSessionStateEntry(b []byte, typ byte, data []byte) []byte {
+	b = append(b, typ)
+	b = appendLengthEncodedInteger(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendLengthEncodedString(b []byte, s string) []byte {
+	b = appendLengthEncodedInteger(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func TestHandleOkPacketSessionState(t *testing.T) {
+	var info []byte
+	info = appendSessionStateEntry(info, sessionTrackSystemVariables,
+		appendLengthEncodedString(appendLengthEncodedString(nil, "autocommit"), "OFF"))
+	info = appendSessionStateEntry(info, sessionTrackSchema, appendLengthEncodedString(nil, "test"))
+	info = appendSessionStateEntry(info, sessionTrackStateChange, appendLengthEncodedString(nil, "1"))
+	info = appendSessionStateEntry(info, sessionTrackGTIDs,
+		appendLengthEncodedString([]byte{0}, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23"))
+	info = appendSessionStateEntry(info, sessionTrackTransactionState, appendLengthEncodedString(nil, "T_______"))
+	info = appendSessionStateEntry(info, 0x7f, []byte("unknown")) // skipped
+
+	// affected rows, insert id, status, warnings, info, session state info
+	status := statusSessionStateChanged | statusInAutocommit
+	data := []byte{iOK, 0, 0, byte(status), byte(status >> 8), 0, 0}
+	data = appendLengthEncodedString(data, "")
+	data = appendLengthEncodedString(data, string(info))
+
+	var handled *SessionState
+	SetSessionStateHandler(func(state *SessionState) {
+		handled = state
+	})
+	defer SetSessionStateHandler(nil)
+
+	mc := &mysqlConn{cfg: &config{}, flags: clientSessionTrack}
+	if err := mc.handleOkPacket(data); err != nil {
+		t.Fatal(err)
+	}
+
+	state := mc.SessionState()
+	if state == nil {
+		t.Fatal("expected a session state")
+	}
+	if handled != state {
+		t.Error("expected the handler to be called with the session state")
+	}
+	if !mc.sessionModified {
+		t.Error("expected the session to be modified")
+	}
+	if len(state.SystemVariables) != 1 || state.SystemVariables["autocommit"] != "OFF" {
+		t.Errorf("unexpected system variables %v", state.SystemVariables)
+	}
+	if state.Schema != "test" || !state.StateChanged || state.TransactionState != "T_______" {
+		t.Errorf("unexpected session state %+v", state)
+	}
+	if state.GTIDs != "3e11fa47-71ca-11e1-9e33-c80aa9429562:23" {
+		t.Errorf("unexpected GTIDs %q", state.GTIDs)
+	}
+}
+
+func TestParseSessionStateMalformed(t *testing.T) {
+	var malformed = [][]byte{
+		{},                           // no info
+		{5, sessionTrackSchema},      // info longer than data
+		{2, sessionTrackSchema, 5},   // entry longer than info
+		{3, sessionTrackGTIDs, 1, 0}, // GTIDs missing
+		{4, sessionTrackSystemVariables, 2, 1, 'a'}, // value missing
+	}
+	for i, data := range malformed {
+		if _, err := parseSessionState(data); err != errMalformPkt {
+			t.Errorf("%d: expected errMalformPkt, got %v", i, err)
+		}
+	}
+}
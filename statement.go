@@ -9,7 +9,10 @@
 package mysql
 
 import (
+	"context"
 	"database/sql/driver"
+	"fmt"
+	"reflect"
 )
 
 type mysqlStmt struct {
@@ -17,6 +20,8 @@ type mysqlStmt struct {
 	id         uint32
 	paramCount int
 	columns    []mysqlField // cached from the first query
+	query      string
+	generation uint32 // session generation of the connection when prepared
 }
 
 func (stmt *mysqlStmt) Close() error {
@@ -25,11 +30,39 @@ func (stmt *mysqlStmt) Close() error {
 		return driver.ErrBadConn
 	}
 
+	// The statement was already closed by a session reset. Its id may have
+	// been reused by the server since.
+	if stmt.generation != stmt.mc.generation {
+		stmt.mc = nil
+		return nil
+	}
+
 	err := stmt.mc.writeCommandPacketUint32(comStmtClose, stmt.id)
 	stmt.mc = nil
 	return err
 }
 
+// Prepares the statement again if it was closed by a session reset
+func (stmt *mysqlStmt) checkGeneration() error {
+	if stmt.generation == stmt.mc.generation {
+		return nil
+	}
+
+	prepared, err := stmt.mc.prepare(stmt.query)
+	if err != nil {
+		return err
+	}
+	if prepared.paramCount != stmt.paramCount {
+		prepared.Close()
+		return fmt.Errorf("Parameter count of re-prepared statement changed (Got: %d Has: %d)",
+			prepared.paramCount, stmt.paramCount)
+	}
+	stmt.id = prepared.id
+	stmt.columns = nil
+	stmt.generation = prepared.generation
+	return nil
+}
+
 func (stmt *mysqlStmt) NumInput() int {
 	return stmt.paramCount
 }
@@ -39,6 +72,10 @@ func (stmt *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
 		errLog.Print(errInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+	if err := stmt.checkGeneration(); err != nil {
+		return nil, err
+	}
+
 	// Send command
 	err := stmt.writeExecutePacket(args)
 	if err != nil {
@@ -49,13 +86,16 @@ func (stmt *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
 
 	mc.affectedRows = 0
 	mc.insertId = 0
+	mc.warningCount = 0
+	mc.info = ""
+	mc.sessionState = nil
 
 	// Read Result
 	resLen, err := mc.readResultSetHeaderPacket()
 	if err == nil {
 		if resLen > 0 {
 			// Columns
-			err = mc.readUntilEOF()
+			err = mc.skipColumns(resLen)
 			if err != nil {
 				return nil, err
 			}
@@ -67,6 +107,9 @@ func (stmt *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
 			return &mysqlResult{
 				affectedRows: int64(mc.affectedRows),
 				insertId:     int64(mc.insertId),
+				warningCount: mc.warningCount,
+				status:       mc.status,
+				info:         mc.info,
 			}, nil
 		}
 	}
@@ -74,11 +117,51 @@ func (stmt *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
 	return nil, err
 }
 
+// ExecContext implements the driver.StmtExecContext interface.
+// The context is only used for query attributes and CaptureGTIDs.
+func (stmt *mysqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	dargs, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.mc != nil {
+		if err = stmt.mc.setQueryAttributes(ctx); err != nil {
+			return nil, err
+		}
+		defer stmt.mc.setQueryAttributes(context.Background())
+	}
+	res, err := stmt.Exec(dargs)
+	if err == nil {
+		stmt.mc.captureGTIDs(gtidToken(ctx))
+	}
+	return res, err
+}
+
+// QueryContext implements the driver.StmtQueryContext interface.
+// The context is only used for query attributes.
+func (stmt *mysqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	dargs, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.mc != nil {
+		if err = stmt.mc.setQueryAttributes(ctx); err != nil {
+			return nil, err
+		}
+		defer stmt.mc.setQueryAttributes(context.Background())
+	}
+	return stmt.Query(dargs)
+}
+
 func (stmt *mysqlStmt) Query(args []driver.Value) (driver.Rows, error) {
 	if stmt.mc.netConn == nil {
 		errLog.Print(errInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+	if err := stmt.checkGeneration(); err != nil {
+		return nil, err
+	}
+
 	// Send command
 	err := stmt.writeExecutePacket(args)
 	if err != nil {
@@ -104,9 +187,58 @@ func (stmt *mysqlStmt) Query(args []driver.Value) (driver.Rows, error) {
 			stmt.columns = rows.columns
 		} else {
 			rows.columns = stmt.columns
-			err = mc.readUntilEOF()
+			err = mc.skipColumns(resLen)
 		}
 	}
 
 	return rows, err
 }
+
+type converter struct{}
+
+// ConvertValue mirrors driver.DefaultParameterConverter, but passes unsigned
+// integers through as uint64 instead of rejecting values >= 1<<63.
+// Decimal values are kept as they are, so they can be sent as DECIMAL.
+func (c converter) ConvertValue(v interface{}) (driver.Value, error) {
+	if driver.IsValue(v) {
+		return v, nil
+	}
+
+	// Types which are sent with their own MySQL type
+	switch v := v.(type) {
+	case Decimal:
+		return v, nil
+	case NullDecimal:
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.Decimal, nil
+	}
+
+	if vr, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		sv, err := vr.Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := sv.(uint64); !ok && !driver.IsValue(sv) {
+			return nil, fmt.Errorf("non-Value type %T returned from Value", sv)
+		}
+		return sv, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		// indirect pointers
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return c.ConvertValue(rv.Elem().Interface())
+	case reflect.Uint, reflect.Uint64:
+		return rv.Uint(), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
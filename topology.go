@@ -0,0 +1,387 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The topology is refreshed every five seconds by default
+const defaultTopologyInterval = 5 * time.Second
+
+// Topology contains the addresses (host:port) of the online members of a
+// cluster.
+type Topology struct {
+	// Primaries are the writable members. Single-primary Group Replication
+	// has one primary, in multi-primary mode and with Galera all members
+	// are primaries.
+	Primaries []string
+
+	// Secondaries are the read-only members.
+	Secondaries []string
+}
+
+// TopologyDiscoverer keeps track of the members of a MySQL Group Replication
+// (InnoDB Cluster, MySQL 8.0+) or MariaDB Galera cluster and feeds them to
+// the hosts of its Primary and Secondaries connectors. The hosts of the DSN
+// are the seeds of the discovery.
+//
+// The topology is read from performance_schema.replication_group_members,
+// or from the wsrep_* status variables with Galera, over a dedicated
+// connection to any member with quorum. New connections then fail over
+// according to the current topology. Existing connections to a former
+// primary are dropped on their next read-only error or connection loss.
+//
+//  discoverer, err := mysql.NewTopologyDiscoverer("user:password@tcp(seed1:3306,seed2:3306)/dbname")
+//  if err != nil {
+//  	...
+//  }
+//  if err = discoverer.Start(ctx); err != nil {
+//  	...
+//  }
+//  defer discoverer.Close()
+//  db := sql.OpenDB(discoverer.ReplicationConnector())
+type TopologyDiscoverer struct {
+	// Interval is the interval of the topology refreshes, five seconds by
+	// default. It is also the timeout of each refresh.
+	Interval time.Duration
+
+	// Primary connects to the primaries, Secondaries to the secondaries
+	// using round-robin. They may be configured, e.g. with a Dialer, before
+	// Start. Secondaries is also used for the discovery.
+	Primary     *Connector
+	Secondaries *Connector
+
+	seeds []string
+
+	refreshMu sync.Mutex
+	conn      *mysqlConn // dedicated connection of the discovery
+
+	mu       sync.Mutex
+	topology Topology
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewTopologyDiscoverer returns a TopologyDiscoverer for the given DSN.
+func NewTopologyDiscoverer(dsn string) (*TopologyDiscoverer, error) {
+	cfg, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	seeds := cfg.addrs
+	if seeds == nil {
+		seeds = []string{cfg.addr}
+	}
+
+	// Secondaries are read-only
+	secondaryCfg := *cfg
+	secondaryCfg.requireWritable = false
+	secondaryCfg.hostPolicy = hostRoundRobin
+
+	d := &TopologyDiscoverer{
+		Primary:     &Connector{cfg: cfg},
+		Secondaries: &Connector{cfg: &secondaryCfg},
+		seeds:       seeds,
+	}
+	d.Primary.hosts = newHostList(cfg)
+	d.Primary.hosts.setAddrs(seeds)
+	d.Secondaries.hosts = newHostList(&secondaryCfg)
+	d.Secondaries.hosts.setAddrs(nil)
+	return d, nil
+}
+
+// ReplicationConnector returns a ReplicationConnector which writes to the
+// primaries and reads from the secondaries.
+func (d *TopologyDiscoverer) ReplicationConnector() *ReplicationConnector {
+	return newReplicationConnector(d.Primary, []*Connector{d.Secondaries})
+}
+
+// Topology returns the current topology.
+func (d *TopologyDiscoverer) Topology() Topology {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.topology
+}
+
+// Start reads the topology and refreshes it periodically until Close is
+// called.
+func (d *TopologyDiscoverer) Start(ctx context.Context) error {
+	if err := d.Refresh(ctx); err != nil {
+		return err
+	}
+
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultTopologyInterval
+	}
+	ctx, d.cancel = context.WithCancel(context.Background())
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			refreshCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := d.Refresh(refreshCtx); err != nil && ctx.Err() == nil {
+				errLog.Print(err)
+			}
+			cancel()
+		}
+	}()
+	return nil
+}
+
+// Close stops the refreshes and closes the connection of the discovery.
+func (d *TopologyDiscoverer) Close() error {
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+
+	d.refreshMu.Lock()
+	defer d.refreshMu.Unlock()
+	if d.conn != nil {
+		d.conn.Close()
+		d.conn = nil
+	}
+	return nil
+}
+
+// Refresh reads the topology from any known member or seed and updates the
+// hosts of the connectors.
+func (d *TopologyDiscoverer) Refresh(ctx context.Context) error {
+	d.refreshMu.Lock()
+	defer d.refreshMu.Unlock()
+
+	// A member which lost the quorum is dropped as well
+	if d.conn != nil {
+		topology, err := d.readTopology(ctx, d.conn)
+		if err == nil {
+			d.update(topology)
+			return nil
+		}
+		d.conn.Close()
+		d.conn = nil
+	}
+
+	err := errNoHosts
+	for _, addr := range d.candidates() {
+		var mc *mysqlConn
+		if mc, err = d.Secondaries.connect(ctx, addr); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+
+		var topology Topology
+		if topology, err = d.readTopology(ctx, mc); err == nil {
+			d.conn = mc
+			d.update(topology)
+			return nil
+		}
+		mc.Close()
+	}
+	return err
+}
+
+// Returns the known members followed by the seeds
+func (d *TopologyDiscoverer) candidates() []string {
+	topology := d.Topology()
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, list := range [][]string{topology.Primaries, topology.Secondaries, d.seeds} {
+		for _, addr := range list {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// readTopology reads the topology over mc. Galera only reports the addresses
+// of the members, so the state of the others is checked over their own
+// connections.
+func (d *TopologyDiscoverer) readTopology(ctx context.Context, mc *mysqlConn) (Topology, error) {
+	topology, galera, err := mc.readTopology(ctx)
+	if err != nil || !galera {
+		return topology, err
+	}
+
+	synced := topology.Primaries[:0]
+	for _, addr := range topology.Primaries {
+		if addr != mc.cfg.addr && !d.isSynced(ctx, addr) {
+			continue
+		}
+		synced = append(synced, addr)
+	}
+	topology.Primaries = synced
+	return topology, nil
+}
+
+// Reports whether the Galera member addr is synced, e.g. not a donor
+func (d *TopologyDiscoverer) isSynced(ctx context.Context, addr string) bool {
+	mc, err := d.Secondaries.connect(ctx, addr)
+	if err != nil {
+		return false
+	}
+	defer mc.Close()
+
+	status, err := mc.galeraStatus(ctx)
+	return err == nil && isGaleraSynced(status)
+}
+
+func (d *TopologyDiscoverer) update(topology Topology) {
+	d.mu.Lock()
+	d.topology = topology
+	d.mu.Unlock()
+
+	d.Primary.hosts.setAddrs(topology.Primaries)
+	d.Secondaries.hosts.setAddrs(topology.Secondaries)
+}
+
+// readTopology reads the online members of the cluster of the server and
+// reports whether it is a Galera cluster.
+func (mc *mysqlConn) readTopology(ctx context.Context) (Topology, bool, error) {
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return Topology{}, false, err
+	}
+	defer clearDeadline()
+
+	// MySQL Group Replication
+	rows, err := mc.queryRows("SELECT MEMBER_HOST, MEMBER_PORT, MEMBER_STATE, MEMBER_ROLE " +
+		"FROM performance_schema.replication_group_members")
+	if err != nil && !hasErrorNumber(err, ER_NO_SUCH_TABLE) {
+		return Topology{}, false, err
+	}
+	var topology Topology
+	var unreachable int
+	for _, row := range rows {
+		if row[2] == "UNREACHABLE" {
+			unreachable++
+		}
+		if row[0] == "" || row[1] == "" || row[2] != "ONLINE" {
+			continue
+		}
+		addr := net.JoinHostPort(row[0], row[1])
+		if row[3] == "PRIMARY" {
+			topology.Primaries = append(topology.Primaries, addr)
+		} else {
+			topology.Secondaries = append(topology.Secondaries, addr)
+		}
+	}
+
+	// A member of a minority partition still reports itself as ONLINE and
+	// the others as UNREACHABLE, but can't commit. RECOVERING members are
+	// reachable and count towards the majority.
+	if len(rows) > 0 && unreachable*2 >= len(rows) {
+		return Topology{}, false, errNoCluster
+	}
+
+	// MariaDB Galera Cluster
+	galera := len(rows) == 0
+	if galera {
+		status, err := mc.galeraStatus(ctx)
+		if err != nil {
+			return Topology{}, false, err
+		}
+		// A node outside of the primary component has an outdated view, a
+		// donor or desynced node is not usable
+		if isGaleraSynced(status) {
+			for _, addr := range strings.Split(status["wsrep_incoming_addresses"], ",") {
+				if _, port, err := net.SplitHostPort(addr); err == nil && port != "" {
+					topology.Primaries = append(topology.Primaries, addr)
+				}
+			}
+		}
+	}
+
+	// Without a primary, the server is not part of the majority
+	if len(topology.Primaries) == 0 {
+		return Topology{}, false, errNoCluster
+	}
+	sort.Strings(topology.Primaries)
+	sort.Strings(topology.Secondaries)
+	return topology, galera, nil
+}
+
+// Galera node state of a node which is in sync with the cluster
+const galeraSynced = "4"
+
+// galeraStatus returns the wsrep status variables of the server.
+func (mc *mysqlConn) galeraStatus(ctx context.Context) (map[string]string, error) {
+	clearDeadline, err := mc.setDeadline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer clearDeadline()
+
+	rows, err := mc.queryRows("SHOW GLOBAL STATUS WHERE Variable_name IN " +
+		"('wsrep_cluster_status', 'wsrep_incoming_addresses', 'wsrep_local_state', 'wsrep_ready')")
+	if err != nil {
+		return nil, err
+	}
+	status := make(map[string]string)
+	for _, row := range rows {
+		status[row[0]] = row[1]
+	}
+	return status, nil
+}
+
+// Reports whether the node is synced with the primary component and accepts
+// queries
+func isGaleraSynced(status map[string]string) bool {
+	return status["wsrep_cluster_status"] == "Primary" &&
+		status["wsrep_local_state"] == galeraSynced && status["wsrep_ready"] == "ON"
+}
+
+// Returns all rows of the query result as strings. NULL values are empty.
+func (mc *mysqlConn) queryRows(query string) ([][]string, error) {
+	rows, err := mc.Query(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result [][]string
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err = rows.Next(dest); err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(dest))
+		for i, value := range dest {
+			if b, ok := value.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		result = append(result, row)
+	}
+}
@@ -0,0 +1,206 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCluster simulates the members of a cluster. Each member answers
+// "SELECT name" with its address and the topology queries with members.
+type fakeCluster struct {
+	mu      sync.Mutex
+	members [][]string // host, port, state, role
+	galera  map[string]string
+	donors  map[string]bool // Galera nodes which are not synced
+}
+
+func (fc *fakeCluster) setMembers(members ...[]string) {
+	fc.mu.Lock()
+	fc.members = members
+	fc.mu.Unlock()
+}
+
+func (fc *fakeCluster) dial(ctx context.Context, addr string) (net.Conn, error) {
+	if strings.HasPrefix(addr, "down") {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return fakeDialer(func(query string) [][]byte {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		switch {
+		case query == "SELECT name":
+			return fakeResultSet([]string{"name"}, []string{addr})
+		case strings.Contains(query, "replication_group_members"):
+			if fc.galera != nil {
+				return [][]byte{append([]byte{iERR, 0x7a, 0x04, '#', '4', '2', 'S', '0', '2'},
+					"Table 'performance_schema.replication_group_members' doesn't exist"...)}
+			}
+			return fakeResultSet([]string{"MEMBER_HOST", "MEMBER_PORT", "MEMBER_STATE", "MEMBER_ROLE"}, fc.members...)
+		case strings.HasPrefix(query, "SHOW GLOBAL STATUS"):
+			var rows [][]string
+			for name, value := range fc.galera {
+				if name == "wsrep_local_state" && fc.donors[addr] {
+					value = "2"
+				}
+				rows = append(rows, []string{name, value})
+			}
+			return fakeResultSet([]string{"Variable_name", "Value"}, rows...)
+		}
+		return nil
+	})(ctx, addr)
+}
+
+func TestTopologyDiscoverer(t *testing.T) {
+	fc := &fakeCluster{}
+	fc.setMembers(
+		[]string{"db1", "3306", "ONLINE", "PRIMARY"},
+		[]string{"db2", "3306", "ONLINE", "SECONDARY"},
+		[]string{"db3", "3306", "RECOVERING", "SECONDARY"},
+		[]string{"db4", "3306", "ONLINE", "SECONDARY"},
+	)
+
+	d, err := NewTopologyDiscoverer("user@tcp(down:3306,seed:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Primary.Dialer = fc.dial
+	d.Secondaries.Dialer = fc.dial
+	if err = d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	want := Topology{Primaries: []string{"db1:3306"}, Secondaries: []string{"db2:3306", "db4:3306"}}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+
+	db := sql.OpenDB(d.ReplicationConnector())
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+
+	var name string
+	if err = db.QueryRow("SELECT name").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "db2:3306" && name != "db4:3306" {
+		t.Errorf("expected read from a secondary, got %s", name)
+	}
+
+	// switchover to db2
+	fc.setMembers(
+		[]string{"db1", "3306", "ONLINE", "SECONDARY"},
+		[]string{"db2", "3306", "ONLINE", "PRIMARY"},
+	)
+	if err = d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want = Topology{Primaries: []string{"db2:3306"}, Secondaries: []string{"db1:3306"}}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+	conn, err := d.Primary.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := conn.(*mysqlConn).cfg.addr; addr != "db2:3306" {
+		t.Errorf("expected connection to the new primary db2:3306, got %s", addr)
+	}
+	conn.Close()
+
+	// no quorum, the topology is kept
+	fc.setMembers([]string{"db2", "3306", "UNREACHABLE", "PRIMARY"})
+	if err = d.Refresh(context.Background()); err != errNoCluster {
+		t.Errorf("expected errNoCluster, got %v", err)
+	}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+
+	// the primary in a minority partition still reports itself as ONLINE
+	fc.setMembers(
+		[]string{"db1", "3306", "UNREACHABLE", "SECONDARY"},
+		[]string{"db2", "3306", "ONLINE", "PRIMARY"},
+		[]string{"db3", "3306", "UNREACHABLE", "SECONDARY"},
+	)
+	if err = d.Refresh(context.Background()); err != errNoCluster {
+		t.Errorf("expected errNoCluster for a minority, got %v", err)
+	}
+	if d.conn != nil {
+		t.Error("expected the connection to the minority to be dropped")
+	}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+
+	// recovering members are reachable and keep the majority
+	fc.setMembers(
+		[]string{"db1", "3306", "RECOVERING", "SECONDARY"},
+		[]string{"db2", "3306", "ONLINE", "PRIMARY"},
+		[]string{"db3", "3306", "RECOVERING", "SECONDARY"},
+	)
+	if err = d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want = Topology{Primaries: []string{"db2:3306"}}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+}
+
+func TestTopologyDiscovererGalera(t *testing.T) {
+	fc := &fakeCluster{galera: map[string]string{
+		"wsrep_cluster_status":     "Primary",
+		"wsrep_incoming_addresses": "10.0.0.2:3306,10.0.0.1:3306,10.0.0.3:3306,AUTO",
+		"wsrep_local_state":        "4",
+		"wsrep_ready":              "ON",
+	}, donors: map[string]bool{"10.0.0.3:3306": true}}
+
+	d, err := NewTopologyDiscoverer("user@tcp(seed:3306)/dbname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Secondaries.Dialer = fc.dial
+	if err = d.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	want := Topology{Primaries: []string{"10.0.0.1:3306", "10.0.0.2:3306"}}
+	if topology := d.Topology(); !reflect.DeepEqual(topology, want) {
+		t.Errorf("expected %+v, got %+v", want, topology)
+	}
+
+	// a node outside of the primary component
+	fc.mu.Lock()
+	fc.galera["wsrep_cluster_status"] = "non-Primary"
+	fc.mu.Unlock()
+	if err = d.Refresh(context.Background()); err != errNoCluster {
+		t.Errorf("expected errNoCluster, got %v", err)
+	}
+
+	// a donor doesn't provide the topology either
+	fc.mu.Lock()
+	fc.galera["wsrep_cluster_status"] = "Primary"
+	fc.donors["seed:3306"] = true
+	fc.donors["10.0.0.1:3306"] = true
+	fc.donors["10.0.0.2:3306"] = true
+	fc.mu.Unlock()
+	if err = d.Refresh(context.Background()); err != errNoCluster {
+		t.Errorf("expected errNoCluster for donors, got %v", err)
+	}
+}
@@ -9,14 +9,21 @@
 package mysql
 
 type mysqlTx struct {
-	mc *mysqlConn
+	mc    *mysqlConn
+	gtids *GTIDToken // records the GTIDs of the commit, see CaptureGTIDs
 }
 
 func (tx *mysqlTx) Commit() (err error) {
 	if tx.mc == nil || tx.mc.netConn == nil {
 		return errInvalidConn
 	}
+	if tx.gtids != nil {
+		tx.mc.sessionState = nil
+	}
 	err = tx.mc.exec("COMMIT")
+	if err == nil {
+		tx.mc.captureGTIDs(tx.gtids)
+	}
 	tx.mc = nil
 	return
 }
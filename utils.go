@@ -15,8 +15,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +27,8 @@ var (
 	errInvalidDSNUnescaped = errors.New("Invalid DSN: Did you forget to escape a param value?")
 	errInvalidDSNAddr      = errors.New("Invalid DSN: Network Address not terminated (missing closing brace)")
 	errInvalidDSNNoSlash   = errors.New("Invalid DSN: Missing the slash separating the database name")
+	errInvalidDSNHosts     = errors.New("Invalid DSN: Empty host in the list of hosts")
+	errInvalidDSNWarnings  = errors.New("Invalid DSN: strict=true can't be combined with warnings")
 )
 
 func init() {
@@ -72,7 +74,9 @@ func DeregisterTLSConfig(key string) {
 
 // parseDSN parses the DSN string to a config
 func parseDSN(dsn string) (cfg *config, err error) {
-	cfg = new(config)
+	cfg = &config{
+		checkConnLiveness: true,
+	}
 
 	// TODO: use strings.IndexByte when we can depend on Go 1.2
 
@@ -160,6 +164,17 @@ func parseDSN(dsn string) (cfg *config, err error) {
 
 	}
 
+	// Multiple hosts: tcp(host1:3306,host2:3306)
+	if cfg.net != "unix" && strings.ContainsRune(cfg.addr, ',') {
+		cfg.addrs = strings.Split(cfg.addr, ",")
+		for _, addr := range cfg.addrs {
+			if addr == "" {
+				return nil, errInvalidDSNHosts
+			}
+		}
+		cfg.addr = cfg.addrs[0]
+	}
+
 	// Set default location if empty
 	if cfg.loc == nil {
 		cfg.loc = time.UTC
@@ -171,6 +186,7 @@ func parseDSN(dsn string) (cfg *config, err error) {
 // parseDSNParams parses the DSN "query string"
 // Values must be url.QueryEscape'ed
 func parseDSNParams(cfg *config, params string) (err error) {
+	var strict, warnings bool
 	for _, v := range strings.Split(params, "&") {
 		param := strings.SplitN(v, "=", 2)
 		if len(param) != 2 {
@@ -196,6 +212,30 @@ func parseDSNParams(cfg *config, params string) (err error) {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
 
+		// Check connections for liveness before reuse
+		case "checkConnLiveness":
+			var isBool bool
+			cfg.checkConnLiveness, isBool = readBool(value)
+			if !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Reset the session before a connection is reused
+		case "resetSession":
+			var isBool bool
+			cfg.resetSession, isBool = readBool(value)
+			if !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Only connect to writable servers
+		case "requireWritable":
+			var isBool bool
+			cfg.requireWritable, isBool = readBool(value)
+			if !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
 		// Use old authentication mode (pre MySQL 4.1)
 		case "allowOldPasswords":
 			var isBool bool
@@ -204,6 +244,26 @@ func parseDSNParams(cfg *config, params string) (err error) {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
 
+		// Backoff period of hosts which are down
+		case "hostBackoff":
+			cfg.hostBackoff, err = time.ParseDuration(value)
+			if err != nil {
+				return
+			}
+
+		// Order in which multiple hosts are tried
+		case "hostPolicy":
+			switch value {
+			case "sequential":
+				cfg.hostPolicy = hostSequential
+			case "random":
+				cfg.hostPolicy = hostRandom
+			case "roundRobin":
+				cfg.hostPolicy = hostRoundRobin
+			default:
+				return fmt.Errorf("Invalid hostPolicy value: %s", value)
+			}
+
 		// Time Location
 		case "loc":
 			if value, err = url.QueryUnescape(value); err != nil {
@@ -214,6 +274,61 @@ func parseDSNParams(cfg *config, params string) (err error) {
 				return
 			}
 
+		// Zero date handling
+		case "zeroDate":
+			switch value {
+			case "zero":
+				cfg.zeroDate = zeroDateZero
+			case "null":
+				cfg.zeroDate = zeroDateNull
+			case "error":
+				cfg.zeroDate = zeroDateError
+			case "minDate":
+				cfg.zeroDate = zeroDateMin
+			default:
+				return fmt.Errorf("Invalid zeroDate value: %s", value)
+			}
+
+		// Strict mode
+		case "strict":
+			var isBool bool
+			strict, isBool = readBool(value)
+			if !isBool {
+				return errors.New("Invalid Bool value: " + value)
+			}
+			if strict {
+				cfg.warnings = warningsAll
+			}
+
+		// Warnings policy
+		case "warnings":
+			warnings = true
+			switch value {
+			case "ignore":
+				cfg.warnings = warningsIgnore
+			case "log":
+				cfg.warnings = warningsLog
+			case "error":
+				cfg.warnings = warningsError
+			case "all":
+				cfg.warnings = warningsAll
+			default:
+				return fmt.Errorf("Invalid warnings value: %s", value)
+			}
+
+		// Session time zone synchronization
+		case "serverTimeZone":
+			switch value {
+			case "off":
+				cfg.serverTimeZone = serverTimeZoneOff
+			case "sync":
+				cfg.serverTimeZone = serverTimeZoneSync
+			case "read":
+				cfg.serverTimeZone = serverTimeZoneRead
+			default:
+				return fmt.Errorf("Invalid serverTimeZone value: %s", value)
+			}
+
 		// Dial Timeout
 		case "timeout":
 			cfg.timeout, err = time.ParseDuration(value)
@@ -250,6 +365,10 @@ func parseDSNParams(cfg *config, params string) (err error) {
 		}
 	}
 
+	// strict=true is a shorthand for warnings=all
+	if strict && warnings {
+		return errInvalidDSNWarnings
+	}
 	return
 }
 
@@ -380,6 +499,70 @@ func scrambleOldPassword(scramble, password []byte) []byte {
 	return out[:]
 }
 
+/******************************************************************************
+*                         Unsigned integer utils                              *
+******************************************************************************/
+
+// NullUint64 represents an uint64 that may be NULL.
+// NullUint64 implements the Scanner interface so
+// it can be used as a scan destination:
+//
+//  var nu NullUint64
+//  err := db.QueryRow("SELECT id FROM foo WHERE name=?", name).Scan(&nu)
+//  ...
+//  if nu.Valid {
+//     // use nu.Uint64
+//  } else {
+//     // NULL value
+//  }
+//
+// Unlike sql.NullInt64 the full range of BIGINT UNSIGNED columns is supported.
+type NullUint64 struct {
+	Uint64 uint64
+	Valid  bool // Valid is true if Uint64 is not NULL
+}
+
+// Scan implements the Scanner interface.
+// The value type must be uint64, a non-negative int64 or string / []byte
+// (decimal number), otherwise Scan fails.
+func (nu *NullUint64) Scan(value interface{}) (err error) {
+	if value == nil {
+		nu.Uint64, nu.Valid = 0, false
+		return
+	}
+
+	switch v := value.(type) {
+	case uint64:
+		nu.Uint64, nu.Valid = v, true
+		return
+	case int64:
+		if v >= 0 {
+			nu.Uint64, nu.Valid = uint64(v), true
+			return
+		}
+	case []byte:
+		nu.Uint64, err = strconv.ParseUint(string(v), 10, 64)
+		nu.Valid = (err == nil)
+		return
+	case string:
+		nu.Uint64, err = strconv.ParseUint(v, 10, 64)
+		nu.Valid = (err == nil)
+		return
+	}
+
+	nu.Valid = false
+	return fmt.Errorf("Can't convert %T (%v) to uint64", value, value)
+}
+
+// Value implements the driver Valuer interface.
+// The returned uint64 is sent as an unsigned BIGINT parameter.
+func (nu NullUint64) Value() (driver.Value, error) {
+	if !nu.Valid {
+		return nil, nil
+	}
+	return nu.Uint64, nil
+}
+
 /******************************************************************************
 *                           Time related utils                                *
 ******************************************************************************/
@@ -465,10 +648,95 @@ func parseDateTime(str string, loc *time.Location) (t time.Time, err error) {
 	return
 }
 
-func parseBinaryDateTime(num uint64, data []byte, loc *time.Location) (driver.Value, error) {
+// namedValuesToValues converts the arguments of the context methods. Named
+// parameters are not supported by MySQL.
+func namedValuesToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	args := make([]driver.Value, len(named))
+	for i, param := range named {
+		if len(param.Name) > 0 {
+			return nil, errors.New("Named parameters are not supported")
+		}
+		args[i] = param.Value
+	}
+	return args, nil
+}
+
+// formatUTCOffset returns the UTC offset of t in the form "+hh:mm"
+func formatUTCOffset(t time.Time) string {
+	_, offset := t.Zone()
+	sign := byte('+')
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	offset /= 60
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/60, offset%60)
+}
+
+// parseUTCOffset parses a time zone offset of the form "+hh:mm" as used by
+// MySQL and returns a fixed time.Location for it
+func parseUTCOffset(str string) (*time.Location, bool) {
+	if len(str) != 6 || (str[0] != '+' && str[0] != '-') || str[3] != ':' {
+		return nil, false
+	}
+	hours, err := strconv.Atoi(str[1:3])
+	if err != nil {
+		return nil, false
+	}
+	minutes, err := strconv.Atoi(str[4:6])
+	if err != nil {
+		return nil, false
+	}
+	offset := (hours*60 + minutes) * 60
+	if str[0] == '-' {
+		offset = -offset
+	}
+	if offset == 0 {
+		return time.UTC, true
+	}
+	return time.FixedZone(str, offset), true
+}
+
+// zeroDateMode controls how zero dates are handled, see the DSN parameter
+// zeroDate.
+type zeroDateMode uint8
+
+const (
+	zeroDateZero  zeroDateMode = iota // time.Time{} / '0000-00-00'
+	zeroDateNull                      // NULL
+	zeroDateError                     // error
+	zeroDateMin                       // '1000-01-01 00:00:00'
+)
+
+// minDateTime is the smallest DATETIME value supported by MySQL
+const minDateTime = "1000-01-01 00:00:00"
+
+// value returns the value a zero date is converted to
+func (mode zeroDateMode) value(loc *time.Location) (driver.Value, error) {
+	switch mode {
+	case zeroDateNull:
+		return nil, nil
+	case zeroDateError:
+		return nil, errZeroDate
+	case zeroDateMin:
+		return time.Date(1000, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, nil
+}
+
+// isZeroDate returns true if the DATE or DATETIME string is a zero date
+// ('0000-00-00') or partially zero ('2020-00-15'), which can't be represented
+// by a time.Time.
+func isZeroDate(b []byte) bool {
+	return len(b) >= 10 && ((b[5] == '0' && b[6] == '0') || (b[8] == '0' && b[9] == '0'))
+}
+
+func parseBinaryDateTime(num uint64, data []byte, loc *time.Location, zeroDate zeroDateMode) (driver.Value, error) {
+	// zero date or partially zero (month or day is 0)
+	if num == 0 || (num >= 4 && (data[2] == 0 || data[3] == 0)) {
+		return zeroDate.value(loc)
+	}
+
 	switch num {
-	case 0:
-		return time.Time{}, nil
 	case 4:
 		return time.Date(
 			int(binary.LittleEndian.Uint16(data[:2])), // year
@@ -600,28 +868,6 @@ func uint64ToBytes(n uint64) []byte {
 	}
 }
 
-func uint64ToString(n uint64) []byte {
-	var a [20]byte
-	i := 20
-
-	// U+0030 = 0
-	// ...
-	// U+0039 = 9
-
-	var q uint64
-	for n >= 10 {
-		i--
-		q = n / 10
-		a[i] = uint8(n-q*10) + 0x30
-		n = q
-	}
-
-	i--
-	a[i] = uint8(n) + 0x30
-
-	return a[i:]
-}
-
 // treats string value as unsigned integer representation
 func stringToInt(b []byte) int {
 	val := 0
@@ -638,17 +884,12 @@ func stringToInt(b []byte) int {
 func readLengthEncodedString(b []byte) ([]byte, bool, int, error) {
 	// Get length
 	num, isNull, n := readLengthEncodedInteger(b)
-	if num < 1 {
-		return b[n:n], isNull, n, nil
-	}
-
-	n += int(num)
 
 	// Check data length
-	if len(b) >= n {
-		return b[n-int(num) : n], false, n, nil
+	if n > len(b) || num > uint64(len(b)-n) {
+		return nil, false, n, errMalformPkt
 	}
-	return nil, false, n, io.EOF
+	return b[n : n+int(num)], isNull, n + int(num), nil
 }
 
 // returns the number of bytes skipped and an error, in case the string is
@@ -656,21 +897,21 @@ func readLengthEncodedString(b []byte) ([]byte, bool, int, error) {
 func skipLengthEncodedString(b []byte) (int, error) {
 	// Get length
 	num, _, n := readLengthEncodedInteger(b)
-	if num < 1 {
-		return n, nil
-	}
-
-	n += int(num)
 
 	// Check data length
-	if len(b) >= n {
-		return n, nil
+	if n > len(b) || num > uint64(len(b)-n) {
+		return n, errMalformPkt
 	}
-	return n, io.EOF
+	return n + int(num), nil
 }
 
 // returns the number read, whether the value is NULL and the number of bytes read
+// The number of bytes read is larger than len(b) if b is too short.
 func readLengthEncodedInteger(b []byte) (uint64, bool, int) {
+	if len(b) == 0 {
+		return 0, false, 1
+	}
+
 	switch b[0] {
 
 	// 251: NULL
@@ -679,14 +920,23 @@ func readLengthEncodedInteger(b []byte) (uint64, bool, int) {
 
 	// 252: value of following 2
 	case 0xfc:
+		if len(b) < 3 {
+			return 0, false, 3
+		}
 		return uint64(b[1]) | uint64(b[2])<<8, false, 3
 
 	// 253: value of following 3
 	case 0xfd:
+		if len(b) < 4 {
+			return 0, false, 4
+		}
 		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, false, 4
 
 	// 254: value of following 8
 	case 0xfe:
+		if len(b) < 9 {
+			return 0, false, 9
+		}
 		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16 |
 				uint64(b[4])<<24 | uint64(b[5])<<32 | uint64(b[6])<<40 |
 				uint64(b[7])<<48 | uint64(b[8])<<56,
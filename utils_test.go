@@ -10,6 +10,7 @@ package mysql
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/binary"
 	"fmt"
 	"testing"
@@ -21,18 +22,22 @@ var testDSNs = []struct {
 	out string
 	loc *time.Location
 }{
-	{"username:password@protocol(address)/dbname?param=value", "&{user:username passwd:password net:protocol addr:address dbname:dbname params:map[param:value] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"user@unix(/path/to/socket)/dbname?charset=utf8", "&{user:user passwd: net:unix addr:/path/to/socket dbname:dbname params:map[charset:utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"user:password@tcp(localhost:5555)/dbname?charset=utf8&tls=true", "&{user:user passwd:password net:tcp addr:localhost:5555 dbname:dbname params:map[charset:utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"user:password@tcp(localhost:5555)/dbname?charset=utf8mb4,utf8&tls=skip-verify", "&{user:user passwd:password net:tcp addr:localhost:5555 dbname:dbname params:map[charset:utf8mb4,utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"user:password@/dbname?loc=UTC&timeout=30s&allowAllFiles=1&clientFoundRows=true&allowOldPasswords=TRUE", "&{user:user passwd:password net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:30000000000 tls:<nil> allowAllFiles:true allowOldPasswords:true clientFoundRows:true}", time.UTC},
-	{"user:p@ss(word)@tcp([de:ad:be:ef::ca:fe]:80)/dbname?loc=Local", "&{user:user passwd:p@ss(word) net:tcp addr:[de:ad:be:ef::ca:fe]:80 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.Local},
-	{"/dbname", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"@/", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"/", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"user:p@/ssword@/", "&{user:user passwd:p@/ssword net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
-	{"unix/?arg=%2Fsome%2Fpath.ext", "&{user: passwd: net:unix addr:/tmp/mysql.sock dbname: params:map[arg:/some/path.ext] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false}", time.UTC},
+	{"username:password@protocol(address)/dbname?param=value", "&{user:username passwd:password net:protocol addr:address dbname:dbname params:map[param:value] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user@unix(/path/to/socket)/dbname?charset=utf8", "&{user:user passwd: net:unix addr:/path/to/socket dbname:dbname params:map[charset:utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:password@tcp(localhost:5555)/dbname?charset=utf8&tls=true", "&{user:user passwd:password net:tcp addr:localhost:5555 dbname:dbname params:map[charset:utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:password@tcp(localhost:5555)/dbname?charset=utf8mb4,utf8&tls=skip-verify", "&{user:user passwd:password net:tcp addr:localhost:5555 dbname:dbname params:map[charset:utf8mb4,utf8] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:password@/dbname?loc=UTC&timeout=30s&allowAllFiles=1&clientFoundRows=true&allowOldPasswords=TRUE", "&{user:user passwd:password net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:30000000000 tls:<nil> allowAllFiles:true allowOldPasswords:true clientFoundRows:true zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:p@ss(word)@tcp([de:ad:be:ef::ca:fe]:80)/dbname?loc=Local", "&{user:user passwd:p@ss(word) net:tcp addr:[de:ad:be:ef::ca:fe]:80 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.Local},
+	{"/dbname", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"@/", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"/", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:p@/ssword@/", "&{user:user passwd:p@/ssword net:tcp addr:127.0.0.1:3306 dbname: params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"/dbname?zeroDate=minDate", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:3 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"/dbname?warnings=error", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:2 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"/dbname?serverTimeZone=sync", "&{user: passwd: net:tcp addr:127.0.0.1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:1 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"unix/?arg=%2Fsome%2Fpath.ext", "&{user: passwd: net:unix addr:/tmp/mysql.sock dbname: params:map[arg:/some/path.ext] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[] hostPolicy:0 hostBackoff:0 requireWritable:false}", time.UTC},
+	{"user:p@ss@tcp(db1:3306,db2:3306,db3)/dbname?hostPolicy=roundRobin&hostBackoff=5s&requireWritable=true", "&{user:user passwd:p@ss net:tcp addr:db1:3306 dbname:dbname params:map[] loc:%p timeout:0 tls:<nil> allowAllFiles:false allowOldPasswords:false clientFoundRows:false zeroDate:0 serverTimeZone:0 warnings:0 checkConnLiveness:true resetSession:false addrs:[db1:3306 db2:3306 db3] hostPolicy:2 hostBackoff:5000000000 requireWritable:true}", time.UTC},
 }
 
 func TestDSNParser(t *testing.T) {
@@ -64,6 +69,12 @@ func TestDSNParserInvalid(t *testing.T) {
 		"(/",                          // no closing brace
 		"net(addr)//",                 // unescaped
 		"user:pass@tcp(1.2.3.4:3306)", // no trailing slash
+		"/dbname?zeroDate=invalid",    // unknown zeroDate mode
+		"/dbname?serverTimeZone=1",    // unknown serverTimeZone mode
+		"/dbname?warnings=strict",     // unknown warnings mode
+		"/?strict=true&warnings=log",  // strict and warnings
+		"tcp(db1:3306,)/",             // empty host
+		"/dbname?hostPolicy=failover", // unknown hostPolicy
 		//"/dbname?arg=/some/unescaped/path",
 	}
 
@@ -126,6 +137,77 @@ func TestScanNullTime(t *testing.T) {
 	}
 }
 
+func TestScanNullUint64(t *testing.T) {
+	var scanTests = []struct {
+		in    interface{}
+		error bool
+		valid bool
+		out   uint64
+	}{
+		{uint64(18446744073709551615), false, true, 18446744073709551615},
+		{int64(42), false, true, 42},
+		{"18446744073709551615", false, true, 18446744073709551615},
+		{[]byte("42"), false, true, 42},
+		{nil, false, false, 0},
+		{int64(-1), true, false, 0},
+		{"-1", true, false, 0},
+		{"18446744073709551616", true, false, 18446744073709551615},
+		{1.5, true, false, 0},
+	}
+
+	var nu = NullUint64{}
+	var err error
+
+	for _, tst := range scanTests {
+		err = nu.Scan(tst.in)
+		if (err != nil) != tst.error {
+			t.Errorf("%v: expected error status %t, got %t", tst.in, tst.error, (err != nil))
+		}
+		if nu.Valid != tst.valid {
+			t.Errorf("%v: expected valid status %t, got %t", tst.in, tst.valid, nu.Valid)
+		}
+		if nu.Valid && nu.Uint64 != tst.out {
+			t.Errorf("%v: expected %d, got %d", tst.in, tst.out, nu.Uint64)
+		}
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	var nilUint64 *uint64
+	var nilNullUint64 *NullUint64
+	u := uint64(1 << 63)
+	var convertTests = []struct {
+		in  interface{}
+		out driver.Value
+	}{
+		{uint64(1 << 63), uint64(1 << 63)},
+		{uint(42), uint64(42)},
+		{&u, uint64(1 << 63)},
+		{nilUint64, nil},
+		{uint32(42), int64(42)},
+		{int(-42), int64(-42)},
+		{"str", "str"},
+		{NullUint64{Uint64: 1 << 63, Valid: true}, uint64(1 << 63)},
+		{NullUint64{}, nil},
+		{nilNullUint64, nil},
+	}
+
+	for _, tst := range convertTests {
+		out, err := converter{}.ConvertValue(tst.in)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", tst.in, err.Error())
+			continue
+		}
+		if out != tst.out {
+			t.Errorf("%v: expected %#v, got %#v", tst.in, tst.out, out)
+		}
+	}
+
+	if _, err := (converter{}).ConvertValue(struct{}{}); err == nil {
+		t.Error("expected error for unsupported type struct{}")
+	}
+}
+
 func TestLengthEncodedInteger(t *testing.T) {
 	var integerTests = []struct {
 		num     uint64
@@ -210,3 +292,79 @@ func TestFormatBinaryDateTime(t *testing.T) {
 	expect("1978-12-30 15:46:23", 7, true)
 	expect("1978-12-30 15:46:23.987654", 11, true)
 }
+
+func TestZeroDate(t *testing.T) {
+	var zeroTests = []struct {
+		in   string
+		zero bool
+	}{
+		{"0000-00-00", true},
+		{"0000-00-00 00:00:00", true},
+		{"2020-00-15", true},
+		{"2020-01-00 12:00:00", true},
+		{"0000-01-01", false},
+		{"2020-01-15", false},
+		{"00:00:00", false},
+	}
+	for _, tst := range zeroTests {
+		if isZeroDate([]byte(tst.in)) != tst.zero {
+			t.Errorf("%s: expected %t", tst.in, tst.zero)
+		}
+	}
+
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	var modeTests = []struct {
+		mode zeroDateMode
+		out  driver.Value
+		err  bool
+	}{
+		{zeroDateZero, time.Time{}, false},
+		{zeroDateNull, nil, false},
+		{zeroDateError, nil, true},
+		{zeroDateMin, time.Date(1000, 1, 1, 0, 0, 0, 0, loc), false},
+	}
+	partial := []byte{0xe4, 0x07, 0, 15} // 2020-00-15
+	for _, tst := range modeTests {
+		for _, num := range []uint64{0, 4} {
+			out, err := parseBinaryDateTime(num, partial, loc, tst.mode)
+			if (err != nil) != tst.err {
+				t.Errorf("mode %d, length %d: expected error status %t, got %v", tst.mode, num, tst.err, err)
+			}
+			if out != tst.out {
+				t.Errorf("mode %d, length %d: expected %v, got %v", tst.mode, num, tst.out, out)
+			}
+		}
+	}
+}
+
+func TestUTCOffset(t *testing.T) {
+	var offsetTests = []struct {
+		str    string
+		offset int
+	}{
+		{"+00:00", 0},
+		{"+05:30", 5*3600 + 30*60},
+		{"-08:00", -8 * 3600},
+		{"+13:00", 13 * 3600},
+	}
+	for _, tst := range offsetTests {
+		loc, ok := parseUTCOffset(tst.str)
+		if !ok {
+			t.Errorf("%s: parsing failed", tst.str)
+			continue
+		}
+		now := time.Now().In(loc)
+		if _, offset := now.Zone(); offset != tst.offset {
+			t.Errorf("%s: expected offset %d, got %d", tst.str, tst.offset, offset)
+		}
+		if str := formatUTCOffset(now); str != tst.str {
+			t.Errorf("expected %s, got %s", tst.str, str)
+		}
+	}
+
+	for _, str := range []string{"SYSTEM", "Europe/Berlin", "+5:30", "+05-30", "+0a:00"} {
+		if _, ok := parseUTCOffset(str); ok {
+			t.Errorf("%s: expected parsing to fail", str)
+		}
+	}
+}
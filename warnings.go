@@ -0,0 +1,131 @@
+// Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2013 The Go-MySQL-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// warningsMode is the policy for warnings reported by the server,
+// see the DSN parameter warnings.
+type warningsMode uint8
+
+const (
+	warningsIgnore warningsMode = iota // only count warnings
+	warningsLog                        // log warnings, except notes
+	warningsError                      // return warnings as error, except notes
+	warningsAll                        // return all warnings as error (strict mode)
+)
+
+// Level of MySQL warnings which are only informational
+const warningLevelNote = "Note"
+
+// WarningCount returns the number of warnings generated by the last
+// statement executed on the connection.
+// The connection can be accessed by sql.Conn.Raw:
+//
+//  err := conn.Raw(func(driverConn interface{}) error {
+//  	count := driverConn.(mysql.Conn).WarningCount()
+//  ...
+func (mc *mysqlConn) WarningCount() uint16 {
+	return mc.warningCount
+}
+
+// Warnings retrieves the warnings generated by the last statement executed
+// on the connection with SHOW WARNINGS. All open rows must be closed before.
+func (mc *mysqlConn) Warnings() (MySQLWarnings, error) {
+	if mc.netConn == nil {
+		errLog.Print(errInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if mc.warningCount == 0 {
+		return nil, nil
+	}
+	return mc.getWarnings()
+}
+
+// Applies the warnings policy after a statement generated warnings
+func (mc *mysqlConn) handleWarnings() error {
+	if mc.warningCount == 0 || mc.cfg.warnings == warningsIgnore {
+		return nil
+	}
+
+	warnings, err := mc.getWarnings()
+	if err != nil {
+		return err
+	}
+	if mc.cfg.warnings != warningsAll {
+		warnings = warnings.withoutNotes()
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	if mc.cfg.warnings == warningsLog {
+		errLog.Print(warnings)
+		return nil
+	}
+	return warnings
+}
+
+func (mc *mysqlConn) getWarnings() (MySQLWarnings, error) {
+	// SHOW WARNINGS doesn't reset the warnings, but its EOF packet would
+	// overwrite the count of the statement
+	warningCount := mc.warningCount
+	defer func() {
+		mc.warningCount = warningCount
+	}()
+
+	rows, err := mc.Query("SHOW WARNINGS", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings = MySQLWarnings{}
+	var values = make([]driver.Value, 3)
+
+	for {
+		err = rows.Next(values)
+		switch err {
+		case nil:
+			warnings = append(warnings, MysqlWarning{
+				Level:   warningString(values[0]),
+				Code:    warningString(values[1]),
+				Message: warningString(values[2]),
+			})
+
+		case io.EOF:
+			return warnings, nil
+
+		default:
+			rows.Close()
+			return nil, err
+		}
+	}
+}
+
+func warningString(v driver.Value) string {
+	if raw, ok := v.([]byte); ok {
+		return string(raw)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// withoutNotes returns the warnings with a level other than Note
+func (mws MySQLWarnings) withoutNotes() MySQLWarnings {
+	filtered := mws[:0:0]
+	for _, warning := range mws {
+		if warning.Level != warningLevelNote {
+			filtered = append(filtered, warning)
+		}
+	}
+	return filtered
+}